@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package cmd
+
+// checkWritable checks if the current user can create files in dir.
+// Permissions are not checked on this platform, where copying reports them instead.
+func checkWritable(dir string) error {
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package cmd
+
+import "syscall"
+
+// accessWrite is the W_OK mode of access(2).
+const accessWrite = 0x2
+
+// checkWritable checks if the current user can create files in dir.
+func checkWritable(dir string) error {
+	return syscall.Access(dir, accessWrite)
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"errors"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const (
+	PlaceholderSrc  = "{src}"
+	PlaceholderDest = "{dest}"
+)
+
+// splitArgs splits the argument string into individual arguments following shell word rules.
+// Arguments are separated by unquoted whitespace.
+// Single quotes preserve their contents literally, while double quotes preserve their contents
+// except for backslash escapes of `"` and `\`.
+// Outside of quotes, a backslash escapes the following character.
+// On Windows, backslashes are path separators and are always taken literally.
+func splitArgs(s string) ([]string, error) {
+	args := make([]string, 0)
+	escapes := runtime.GOOS != "windows"
+
+	var arg strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			if quote == '"' && r != '"' && r != '\\' {
+				arg.WriteRune('\\')
+			}
+			arg.WriteRune(r)
+			escaped = false
+
+		case r == '\\' && escapes && quote != '\'':
+			escaped = true
+			inArg = true
+
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if escaped {
+		return nil, errors.New("trailing backslash in arguments")
+	}
+
+	if quote != 0 {
+		return nil, errors.New("unterminated quote in arguments")
+	}
+
+	if inArg {
+		args = append(args, arg.String())
+	}
+
+	return args, nil
+}
+
+// hasPlaceholders checks if any of the arguments contain the src or dest placeholder.
+func hasPlaceholders(args []string) bool {
+	for _, arg := range args {
+		if strings.Contains(arg, PlaceholderSrc) || strings.Contains(arg, PlaceholderDest) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// expandArgs returns the arguments for copying src to dest.
+// Placeholders in the arguments are replaced with src and dest.
+// If there are no placeholders, src and dest are appended to the arguments instead.
+func expandArgs(args []string, src, dest string) []string {
+	if !hasPlaceholders(args) {
+		expanded := make([]string, 0, len(args)+2)
+		expanded = append(expanded, args...)
+
+		return append(expanded, src, dest)
+	}
+
+	replacer := strings.NewReplacer(PlaceholderSrc, src, PlaceholderDest, dest)
+
+	expanded := make([]string, 0, len(args))
+	for _, arg := range args {
+		expanded = append(expanded, replacer.Replace(arg))
+	}
+
+	return expanded
+}
+
+// joinArgs joins the arguments into a single string for display.
+// Arguments that are empty or contain whitespace or quotes are quoted.
+func joinArgs(args []string) string {
+	quoted := make([]string, 0, len(args))
+
+	for _, arg := range args {
+		if len(arg) < 1 || strings.ContainsAny(arg, " \t\n\r'\"") {
+			arg = strconv.Quote(arg)
+		}
+
+		quoted = append(quoted, arg)
+	}
+
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+		// escapes is whether the case needs backslash escapes, which are not used on Windows.
+		escapes bool
+	}{
+		{in: "", want: []string{}},
+		{in: "  \t ", want: []string{}},
+		{in: "-avr", want: []string{"-avr"}},
+		{in: " -a  -v\t-r\n", want: []string{"-a", "-v", "-r"}},
+		{in: `/E /COPY:DAT`, want: []string{"/E", "/COPY:DAT"}},
+		{in: `--exclude '*.tmp' {src} {dest}`, want: []string{"--exclude", "*.tmp", "{src}", "{dest}"}},
+		{in: `-e "ssh -p 22"`, want: []string{"-e", "ssh -p 22"}},
+		{in: `a'b c'd`, want: []string{"ab cd"}},
+		{in: `'' ""`, want: []string{"", ""}},
+		{in: `'it"s'`, want: []string{`it"s`}},
+		{in: `a\ b`, want: []string{"a b"}, escapes: true},
+		{in: `'a\b'`, want: []string{`a\b`}, escapes: true},
+		{in: `"a\"b\\c\d"`, want: []string{`a"b\c\d`}, escapes: true},
+	}
+
+	windows := runtime.GOOS == "windows"
+
+	for _, test := range tests {
+		if test.escapes && windows {
+			continue
+		}
+
+		got, err := splitArgs(test.in)
+		if err != nil {
+			t.Errorf("splitArgs(%q) returned error: %v", test.in, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("splitArgs(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestSplitArgsWindows(t *testing.T) {
+	if runtime.GOOS != "windows" {
+		t.Skip("backslashes are escapes outside of Windows")
+	}
+
+	got, err := splitArgs(`C:\tmp\x "D:\a b\"`)
+	if err != nil || !reflect.DeepEqual(got, []string{`C:\tmp\x`, `D:\a b\`}) {
+		t.Errorf("splitArgs = %q, %v", got, err)
+	}
+}
+
+func TestSplitArgsErrors(t *testing.T) {
+	inputs := []string{`'unterminated`, `"unterminated`}
+	if runtime.GOOS != "windows" {
+		inputs = append(inputs, `trailing\`)
+	}
+
+	for _, in := range inputs {
+		_, err := splitArgs(in)
+		if err == nil {
+			t.Errorf("splitArgs(%q) returned no error", in)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+)
+
+// Backend copies manifest entries.
+type Backend interface {
+	// Name returns the name of the backend.
+	Name() string
+
+	// DefaultArgs returns the arguments used when no arguments are specified.
+	DefaultArgs() string
+
+	// Prepare readies the backend to copy with the given utility and arguments.
+	Prepare(util string, args []string) error
+
+	// Command returns the command line that copies src to dest.
+	Command(src, dest string) []string
+
+	// Copy copies src to dest and reports the result.
+	Copy(src, dest string) Result
+}
+
+// Result is the outcome of copying a manifest entry with a Backend.
+// Code is the exit code of the copying utility, or -1 if the utility could not be run.
+type Result struct {
+	Class  ExitClass
+	Code   int
+	Err    error
+	Output []byte
+}
+
+var backendsMutex sync.Mutex
+
+// backends maps the name of each known copying utility to its Backend constructor.
+var backends = map[string]func() Backend{
+	UtilBuiltin: newBuiltinBackend,
+	"rsync":     func() Backend { return newExecBackend("rsync", "-avr") },
+	"robocopy":  func() Backend { return newExecBackend("robocopy", "/E /COPY:DAT") },
+	"cp":        newCpBackend,
+}
+
+// RegisterBackend registers the Backend constructor for the named copying utility, replacing the
+// existing constructor for the utility.
+// The name is matched against the base name of the value of -util, ignoring case and the .exe
+// extension.
+func RegisterBackend(name string, fn func() Backend) {
+	backendsMutex.Lock()
+	defer backendsMutex.Unlock()
+
+	backends[utilName(name)] = fn
+}
+
+// newBackend returns the Backend for the copying utility.
+// Utilities without a registered Backend are executed as is, without default arguments.
+func newBackend(util string) Backend {
+	backendsMutex.Lock()
+	fn, found := backends[utilName(util)]
+	backendsMutex.Unlock()
+
+	if !found {
+		return newExecBackend(utilName(util), "")
+	}
+
+	return fn()
+}
+
+// execBackend copies by executing an external copying utility.
+// Its exit codes are interpreted through the exit code table.
+type execBackend struct {
+	name        string
+	defaultArgs string
+	util        string
+	args        []string
+}
+
+func newExecBackend(name, defaultArgs string) Backend {
+	return &execBackend{
+		name:        name,
+		defaultArgs: defaultArgs,
+	}
+}
+
+func (b *execBackend) Name() string {
+	return b.name
+}
+
+func (b *execBackend) DefaultArgs() string {
+	return b.defaultArgs
+}
+
+// Prepare locates the utility in PATH.
+func (b *execBackend) Prepare(util string, args []string) error {
+	path, err := exec.LookPath(util)
+	if err != nil {
+		return err
+	}
+
+	if len(path) < 1 {
+		return errors.New("utility " + util + " not found")
+	}
+
+	b.util = path
+	b.args = args
+
+	return nil
+}
+
+func (b *execBackend) Command(src, dest string) []string {
+	return append([]string{b.util}, expandArgs(b.args, src, dest)...)
+}
+
+// Copy creates the directories leading to the copy before executing the utility, as utilities
+// differ in which of them they create.
+// If src is copied into dest, as located by copyTarget, dest itself is created.
+func (b *execBackend) Copy(src, dest string) Result {
+	dir := filepath.Clean(dest)
+	if target, err := copyTarget(src, dest); err != nil || target == dir {
+		dir = filepath.Dir(dir)
+	}
+
+	err := os.MkdirAll(dir, dirPerm)
+	if err != nil {
+		return Result{Class: ExitFatal, Code: -1, Err: err}
+	}
+
+	cmd := exec.Command(b.util, expandArgs(b.args, src, dest)...)
+	stdout, err := cmd.Output()
+	if err == nil {
+		return Result{Class: ExitSuccess, Output: stdout}
+	}
+
+	class := ExitFatal
+	code := -1
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code = exitErr.ExitCode()
+		class = classifyExit(b.util, code)
+	}
+
+	return Result{Class: class, Code: code, Err: err, Output: stdout}
+}
+
+// cpBackend copies by executing cp.
+// cp copies a src directory with a trailing slash as a child of an existing dest, so the src is
+// given to cp as the "." entry of the directory, which copies its contents into dest instead.
+type cpBackend struct {
+	*execBackend
+}
+
+func newCpBackend() Backend {
+	return &cpBackend{
+		execBackend: &execBackend{
+			name:        "cp",
+			defaultArgs: "-Rp",
+		},
+	}
+}
+
+func (b *cpBackend) Command(src, dest string) []string {
+	return b.execBackend.Command(cpSource(src), dest)
+}
+
+func (b *cpBackend) Copy(src, dest string) Result {
+	return b.execBackend.Copy(cpSource(src), dest)
+}
+
+// cpSource returns src as given to cp.
+func cpSource(src string) string {
+	if hasTrailingSlash(src) {
+		return src + "."
+	}
+
+	return src
+}
+
+// builtinBackend copies with the native copy engine.
+type builtinBackend struct{}
+
+func newBuiltinBackend() Backend {
+	return &builtinBackend{}
+}
+
+func (b *builtinBackend) Name() string {
+	return UtilBuiltin
+}
+
+func (b *builtinBackend) DefaultArgs() string {
+	return ""
+}
+
+// Prepare does nothing, as the native copy engine takes no arguments.
+func (b *builtinBackend) Prepare(util string, args []string) error {
+	return nil
+}
+
+func (b *builtinBackend) Command(src, dest string) []string {
+	return []string{UtilBuiltin, src, dest}
+}
+
+func (b *builtinBackend) Copy(src, dest string) Result {
+	err := copyBuiltin(src, dest)
+	if err != nil {
+		return Result{Class: ExitFatal, Code: 1, Err: err}
+	}
+
+	return Result{Class: ExitSuccess}
+}
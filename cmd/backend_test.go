@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// TestCpBackendTrailingSlash checks that cp copies the contents of a src directory with a trailing
+// slash into dest, whether or not dest exists.
+func TestCpBackendTrailingSlash(t *testing.T) {
+	if _, err := exec.LookPath("cp"); err != nil {
+		t.Skip("cp not found")
+	}
+
+	dir := t.TempDir()
+
+	src := filepath.Join(dir, "s")
+	dest := filepath.Join(dir, "d")
+
+	err := os.MkdirAll(src, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(filepath.Join(src, "f"), []byte("f"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := newBackend("cp")
+
+	args, err := splitArgs(b.DefaultArgs())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = b.Prepare("cp", args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		r := b.Copy(src+PathSep, dest)
+		if r.Err != nil {
+			t.Fatalf("copy %d: %v: %s", i, r.Err, r.Output)
+		}
+	}
+
+	_, err = os.Stat(filepath.Join(dest, "f"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = os.Stat(filepath.Join(dest, "s"))
+	if !os.IsNotExist(err) {
+		t.Errorf("src directory copied into dest: %v", err)
+	}
+}
@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// UtilBuiltin selects the native copy engine instead of an external copying utility.
+const UtilBuiltin = "builtin"
+
+const dirPerm = fs.FileMode(0755)
+
+// dirTimes holds the directories whose mode and modification time are to be restored after their
+// contents have been copied.
+type dirTimes struct {
+	path string
+	mode fs.FileMode
+	mod  time.Time
+}
+
+// copyTarget returns the path that src will be copied to when copied into dest.
+// It follows rsync semantics: a src directory with a trailing slash has its contents copied into
+// dest, while a src directory without one is copied into dest as a child of the same name.
+// A src file is copied into dest if dest has a trailing slash or is an existing directory.
+// Otherwise, dest is the path of the copy.
+func copyTarget(src, dest string) (string, error) {
+	s, err := os.Stat(src)
+	if err != nil {
+		return "", err
+	}
+
+	srcName := filepath.Base(filepath.Clean(src))
+	destClean := filepath.Clean(dest)
+
+	if s.IsDir() {
+		if hasTrailingSlash(src) {
+			return destClean, nil
+		}
+
+		return filepath.Join(destClean, srcName), nil
+	}
+
+	if hasTrailingSlash(src) {
+		return "", errors.New(src + " is not a directory")
+	}
+
+	if hasTrailingSlash(dest) {
+		return filepath.Join(destClean, srcName), nil
+	}
+
+	d, err := os.Stat(destClean)
+	if err == nil && d.IsDir() {
+		return filepath.Join(destClean, srcName), nil
+	}
+
+	return destClean, nil
+}
+
+// copyBuiltin copies src to dest without relying on an external copying utility.
+// Directories are walked recursively.
+// Mode bits and modification times are preserved, and symbolic links are recreated as is, except
+// for a src with a trailing slash, whose link is followed to copy the contents of its directory.
+func copyBuiltin(src, dest string) error {
+	target, err := copyTarget(src, dest)
+	if err != nil {
+		return err
+	}
+
+	// The contents of a directory with a trailing slash are copied, even if it is reached through
+	// a symbolic link.
+	root := filepath.Clean(src)
+	if hasTrailingSlash(src) {
+		root, err = filepath.EvalSymlinks(root)
+		if err != nil {
+			return err
+		}
+	}
+	dirs := make([]dirTimes, 0)
+
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		to := filepath.Join(target, rel)
+
+		info, err := os.Lstat(path)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case info.IsDir():
+			// Keep the directory writable until its contents have been copied.
+			err = os.MkdirAll(to, info.Mode().Perm()|0700)
+			if err != nil {
+				return err
+			}
+			dirs = append(dirs, dirTimes{path: to, mode: info.Mode().Perm(), mod: info.ModTime()})
+
+		case info.Mode()&fs.ModeSymlink != 0:
+			return copySymlink(path, to)
+
+		case info.Mode().IsRegular():
+			return copyFile(path, to, info)
+
+		default:
+			return errors.New("unsupported file type for " + path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Restore directories deepest first, so that restoring a directory is not undone by changes
+	// to its children.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		err = os.Chmod(dirs[i].path, dirs[i].mode)
+		if err != nil {
+			return err
+		}
+
+		err = os.Chtimes(dirs[i].path, dirs[i].mod, dirs[i].mod)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyFile copies the contents of the regular file src to dest, then applies the mode bits and
+// modification time of src to dest.
+func copyFile(src, dest string, info fs.FileInfo) error {
+	err := os.MkdirAll(filepath.Dir(dest), dirPerm)
+	if err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm()|0200)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	if err != nil {
+		out.Close()
+		return err
+	}
+
+	err = out.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(dest, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	return os.Chtimes(dest, info.ModTime(), info.ModTime())
+}
+
+// copySymlink recreates the symbolic link src at dest, replacing whatever dest currently is.
+// Directories are never replaced.
+func copySymlink(src, dest string) error {
+	link, err := os.Readlink(src)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(dest), dirPerm)
+	if err != nil {
+		return err
+	}
+
+	if info, err := os.Lstat(dest); err == nil && info.IsDir() {
+		return errors.New("cannot replace directory " + dest + " with symbolic link " + src)
+	}
+
+	err = os.Remove(dest)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return os.Symlink(link, dest)
+}
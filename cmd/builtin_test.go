@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// writeTree creates the files of the tree below dir, each holding its base name.
+func writeTree(t *testing.T, dir string, files ...string) {
+	t.Helper()
+
+	for _, name := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = os.WriteFile(path, []byte(filepath.Base(path)), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// checkTree checks that the files of the tree exist below dir, each holding its base name.
+func checkTree(t *testing.T, dir string, files ...string) {
+	t.Helper()
+
+	for _, name := range files {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if want := filepath.Base(filepath.FromSlash(name)); string(data) != want {
+			t.Errorf("%s holds %q, want %q", name, data, want)
+		}
+	}
+}
+
+func TestCopyBuiltinTrailingSlash(t *testing.T) {
+	dir := t.TempDir()
+
+	src := filepath.Join(dir, "s")
+	writeTree(t, src, "f", "sub/g")
+
+	contents := filepath.Join(dir, "contents")
+	for i := 0; i < 2; i++ {
+		err := copyBuiltin(src+PathSep, contents)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkTree(t, contents, "f", "sub/g")
+
+	if _, err := os.Stat(filepath.Join(contents, "s")); !os.IsNotExist(err) {
+		t.Errorf("src directory copied into dest: %v", err)
+	}
+
+	child := filepath.Join(dir, "child")
+	err := copyBuiltin(src, child)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTree(t, child, "s/f", "s/sub/g")
+}
+
+// TestCopyBuiltinSymlinks checks that symbolic links are recreated as is, unless the source is a
+// link to a directory with a trailing slash, which is followed.
+func TestCopyBuiltinSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links need privileges on Windows")
+	}
+
+	dir := t.TempDir()
+
+	src := filepath.Join(dir, "src")
+	writeTree(t, src, "a/f")
+
+	link := filepath.Join(src, "linka")
+	err := os.Symlink("a", link)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "out")
+	err = os.Mkdir(out, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = copyBuiltin(link+PathSep, out+PathSep)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Lstat(out)
+	if err != nil || !info.IsDir() {
+		t.Fatalf("dest is no longer a directory: %v, %v", info, err)
+	}
+	checkTree(t, out, "f")
+
+	err = copyBuiltin(link, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target, err := os.Readlink(filepath.Join(out, "linka"))
+	if err != nil || target != "a" {
+		t.Errorf("link copied as %q, %v, want link to a", target, err)
+	}
+
+	err = copySymlink(link, out)
+	if err == nil {
+		t.Error("replacing a directory with a symbolic link returned no error")
+	}
+}
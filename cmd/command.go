@@ -5,9 +5,13 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/ghifari160/migrate/internal/logger"
 )
 
 const ManifestName = "manifest.txt"
+const LogDir = "logs"
+
 const ManifestSep = ";"
 const PathSep = string(filepath.Separator)
 
@@ -19,6 +23,13 @@ type Cmd interface {
 	private() // prevent external functions from meeting the interface criteria
 }
 
+// logConf configures the logs shared by every command.
+type logConf struct {
+	dir    string
+	perRun bool
+	format string
+}
+
 func NewFlagSet(name string) *flag.FlagSet {
 	flag := flag.NewFlagSet(name, flag.ContinueOnError)
 	flag.Usage = func() {}
@@ -37,6 +48,90 @@ func PrintDefaults(f *flag.FlagSet) string {
 	return s.String()
 }
 
+// logFlags defines the logging flags on the flag set.
+func logFlags(f *flag.FlagSet, c *logConf) {
+	if len(c.dir) < 1 {
+		c.dir = LogDir
+	}
+
+	if len(c.format) < 1 {
+		c.format = string(logger.FormatText)
+	}
+
+	f.StringVar(&c.dir, "log-dir", c.dir, "Logging directory.")
+	f.BoolVar(&c.perRun, "log-run", c.perRun, "Log each run to a timestamped subdirectory of the logging directory.")
+	f.StringVar(&c.format, "log-format", c.format, "Log format (text, json).")
+}
+
+// manifestFlags defines the manifest flags on the flag set.
+func manifestFlags(f *flag.FlagSet, c *manifestConf) {
+	if len(c.format) < 1 {
+		c.format = FormatAuto
+	}
+
+	if len(c.csvSrc) < 1 {
+		c.csvSrc = CSVSrcColumn
+	}
+
+	if len(c.csvDest) < 1 {
+		c.csvDest = CSVDestColumn
+	}
+
+	if c.vars == nil {
+		c.vars = make(varsFlag)
+	}
+
+	if c.rewrites == nil {
+		c.rewrites = &rewriteRules{}
+	}
+
+	f.StringVar(&c.format, "format", c.format, "Manifest format (auto, text, jsonl, yaml, csv). "+
+		"Auto detects the format from the manifest file extension.")
+	f.StringVar(&c.csvSrc, "csv-src", c.csvSrc, "CSV manifest column holding the source paths.")
+	f.StringVar(&c.csvDest, "csv-dest", c.csvDest, "CSV manifest column holding the destination paths.")
+	f.Var(c.vars, "var", "Manifest variable as NAME=VALUE, referenced as ${NAME} in manifest paths. Can be repeated.")
+	f.Var(c.rewrites, "rewrite", "Rewrite rule as s#REGEXP#REPLACEMENT#, applied to source paths to produce the "+
+		"path of the copy. \\1 refers to the first submatch. Can be repeated.")
+	f.Var(rewriteFile{rules: c.rewrites}, "rewrite-file", "File of rewrite rules, one per line. Can be repeated.")
+}
+
+// valid checks if the logging configuration is valid.
+func (c logConf) valid() bool {
+	if len(c.dir) < 1 {
+		return false
+	}
+
+	_, err := logger.ParseFormat(c.format)
+	return err == nil
+}
+
+// openLogs opens the logs as configured.
+func openLogs(c logConf) (*logger.Logger, error) {
+	format, err := logger.ParseFormat(c.format)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.perRun {
+		return logger.OpenRunLogs(c.dir, format)
+	}
+
+	return logger.OpenLogsFormat(c.dir, format)
+}
+
+// isFlagSet checks if the named flag was set on the command line.
+func isFlagSet(f *flag.FlagSet, name string) bool {
+	set := false
+
+	f.Visit(func(fl *flag.Flag) {
+		if fl.Name == name {
+			set = true
+		}
+	})
+
+	return set
+}
+
 // PreserveTrailingSlash reintroduces trailing slash based on the original path string.
 // If the original string ends with a trailing slash, it is reintroduced to the normalized string.
 // Otherwise, the normalized string is returned as is.
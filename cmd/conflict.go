@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghifari160/migrate/internal/logger"
+)
+
+// manifestIndex indexes manifest entries by mapping and by the path of their copy, detecting
+// duplicate entries and entries whose copies collide.
+type manifestIndex struct {
+	caseInsensitive bool
+	mappings        map[string]bool
+	targets         map[string]manifestEntry
+}
+
+func newManifestIndex(caseInsensitive bool) *manifestIndex {
+	return &manifestIndex{
+		caseInsensitive: caseInsensitive,
+		mappings:        make(map[string]bool),
+		targets:         make(map[string]manifestEntry),
+	}
+}
+
+// add indexes the entry.
+// It reports whether the entry duplicates an indexed entry, or returns the indexed entry whose
+// copy collides with the copy of the entry.
+// Duplicates are not indexed again.
+func (x *manifestIndex) add(e manifestEntry) (bool, *manifestEntry) {
+	mapping := e.src + "\x00" + e.dest
+	if x.mappings[mapping] {
+		return true, nil
+	}
+	x.mappings[mapping] = true
+
+	target := entryTarget(e.src, e.dest)
+	if x.caseInsensitive {
+		target = strings.ToLower(target)
+	}
+
+	if other, found := x.targets[target]; found {
+		return false, &other
+	}
+	x.targets[target] = e
+
+	return false, nil
+}
+
+// entryTarget returns the path src will be copied to when copied into dest.
+// It follows the semantics of copyTarget, except that a dest that does not exist yet is assumed
+// to be a directory, as are the destinations written by generate.
+// A src that cannot be examined is assumed to be a directory.
+func entryTarget(src, dest string) string {
+	srcName := filepath.Base(filepath.Clean(src))
+	destClean := filepath.Clean(dest)
+
+	s, err := os.Stat(src)
+	if err != nil || s.IsDir() {
+		if hasTrailingSlash(src) {
+			return destClean
+		}
+
+		return filepath.Join(destClean, srcName)
+	}
+
+	d, err := os.Stat(destClean)
+	if err == nil && !d.IsDir() && !hasTrailingSlash(dest) {
+		return destClean
+	}
+
+	return filepath.Join(destClean, srcName)
+}
+
+// describeEntry describes the entry by its source and, for entries read from a manifest, location.
+func describeEntry(e manifestEntry) string {
+	if e.line < 1 {
+		return e.src
+	}
+
+	return e.src + " at " + e.location()
+}
+
+// indexManifest indexes the entries of the manifest.
+// Relative paths are resolved against the directory of the manifest.
+// Glob patterns and rewrite rules are not applied, as the entries are indexed as written.
+// Invalid entries are logged and skipped.
+func indexManifest(log *logger.Logger, x *manifestIndex, manifest string, conf manifestConf) error {
+	m, err := openManifest(log, manifest, conf)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	m.base = filepath.Dir(m.name)
+
+	for {
+		e, err := readFormatEntry(m)
+		if err != nil {
+			if errors.Is(err, errManifest) {
+				log.Log(logger.LevelWARN, "Error: "+err.Error())
+				continue
+			}
+
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return err
+		}
+
+		if dup, other := x.add(e); dup {
+			log.Log(logger.LevelWARN, "Duplicate entry "+describeEntry(e)+" in existing manifest.")
+		} else if other != nil {
+			log.Log(logger.LevelWARN, "Destination conflict: "+describeEntry(e)+" and "+describeEntry(*other)+
+				" both copy to "+entryTarget(e.src, e.dest)+".")
+		}
+	}
+}
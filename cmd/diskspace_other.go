@@ -0,0 +1,15 @@
+//go:build !linux && !darwin && !windows
+// +build !linux,!darwin,!windows
+
+package cmd
+
+import (
+	"errors"
+	"runtime"
+)
+
+// statDisk returns the free space of the file system holding path.
+// It is not supported on this platform.
+func statDisk(path string) (diskSpace, error) {
+	return diskSpace{}, errors.New("free space is not supported on " + runtime.GOOS)
+}
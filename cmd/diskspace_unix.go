@@ -0,0 +1,32 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package cmd
+
+import (
+	"strconv"
+	"syscall"
+)
+
+// statDisk returns the free space of the file system holding path.
+func statDisk(path string) (diskSpace, error) {
+	var st syscall.Stat_t
+	err := syscall.Stat(path, &st)
+	if err != nil {
+		return diskSpace{}, err
+	}
+
+	var fs syscall.Statfs_t
+	err = syscall.Statfs(path, &fs)
+	if err != nil {
+		return diskSpace{}, err
+	}
+
+	return diskSpace{
+		id:        strconv.FormatUint(uint64(st.Dev), 10),
+		free:      uint64(fs.Bavail) * uint64(fs.Bsize),
+		freeFiles: uint64(fs.Ffree),
+		// file systems allocating inodes dynamically report no inodes at all
+		hasFiles: fs.Files > 0,
+	}, nil
+}
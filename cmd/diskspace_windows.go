@@ -0,0 +1,40 @@
+//go:build windows
+// +build windows
+
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var procGetDiskFreeSpaceExW = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// statDisk returns the free space of the volume holding path.
+// Windows does not limit the number of files, so it is not reported.
+func statDisk(path string) (diskSpace, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return diskSpace{}, err
+	}
+
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return diskSpace{}, err
+	}
+
+	var free, total, totalFree uint64
+
+	r, _, err := procGetDiskFreeSpaceExW.Call(uintptr(unsafe.Pointer(p)),
+		uintptr(unsafe.Pointer(&free)), uintptr(unsafe.Pointer(&total)), uintptr(unsafe.Pointer(&totalFree)))
+	if r == 0 {
+		return diskSpace{}, err
+	}
+
+	return diskSpace{
+		id:   strings.ToUpper(filepath.VolumeName(path)),
+		free: free,
+	}, nil
+}
@@ -7,6 +7,7 @@ var errManifest = newManifestErr(-1, "")
 
 // manifestErr is an error type for manifest reading and parsing.
 type manifestErr struct {
+	file string
 	line int
 	msg  string
 }
@@ -17,7 +18,18 @@ func newManifestErr(line int, msg string) error {
 	return &manifestErr{line: line, msg: msg}
 }
 
+// newManifestFileErr returns an error from the given file, line number, and text.
+// It is used for errors in included manifests.
+// Each call returns a distinct error value
+func newManifestFileErr(file string, line int, msg string) error {
+	return &manifestErr{file: file, line: line, msg: msg}
+}
+
 func (e *manifestErr) Error() string {
+	if len(e.file) > 0 {
+		return fmt.Sprintf("%s at line %d of %s", e.msg, e.line, e.file)
+	}
+
 	return fmt.Sprintf("%s at line %d", e.msg, e.line)
 }
 
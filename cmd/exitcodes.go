@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ExitClass classifies the exit code of a copying utility.
+type ExitClass int
+
+const (
+	ExitSuccess ExitClass = iota
+	ExitWarning
+	ExitPartial
+	ExitFatal
+)
+
+// ExitCodeFunc classifies the exit code of a copying utility.
+type ExitCodeFunc func(code int) ExitClass
+
+var exitCodesMutex sync.Mutex
+
+// exitCodes maps the name of each copying utility to its exit code semantics.
+var exitCodes = map[string]ExitCodeFunc{
+	"rsync":    rsyncExitCodes,
+	"robocopy": robocopyExitCodes,
+}
+
+// RegisterExitCodes registers the exit code semantics of the named copying utility, replacing
+// existing semantics for the utility.
+// The name is matched against the base name of the utility, ignoring case and the .exe extension.
+func RegisterExitCodes(util string, fn ExitCodeFunc) {
+	exitCodesMutex.Lock()
+	defer exitCodesMutex.Unlock()
+
+	exitCodes[utilName(util)] = fn
+}
+
+// classifyExit classifies the exit code of the copying utility.
+// Utilities without registered semantics treat any non-zero exit code as fatal.
+func classifyExit(util string, code int) ExitClass {
+	exitCodesMutex.Lock()
+	fn, found := exitCodes[utilName(util)]
+	exitCodesMutex.Unlock()
+
+	if !found {
+		return defaultExitCodes(code)
+	}
+
+	return fn(code)
+}
+
+// utilName normalizes the name or path of a copying utility for exit code lookups.
+func utilName(util string) string {
+	return strings.TrimSuffix(strings.ToLower(filepath.Base(util)), ".exe")
+}
+
+// defaultExitCodes treats zero as success and anything else as fatal.
+func defaultExitCodes(code int) ExitClass {
+	if code == 0 {
+		return ExitSuccess
+	}
+
+	return ExitFatal
+}
+
+// rsyncExitCodes classifies rsync exit codes.
+// 23 indicates a partial transfer due to errors, while 24 indicates that some source files
+// vanished before they could be transferred.
+func rsyncExitCodes(code int) ExitClass {
+	switch code {
+	case 0:
+		return ExitSuccess
+
+	case 23:
+		return ExitPartial
+
+	case 24:
+		return ExitWarning
+
+	default:
+		return ExitFatal
+	}
+}
+
+// robocopyExitCodes classifies robocopy exit codes.
+// Robocopy exit codes are bit flags: 1 indicates that files were copied, 2 that extra files
+// were detected, 4 that mismatched files were detected, 8 that some files could not be copied,
+// and 16 that a fatal error occurred.
+func robocopyExitCodes(code int) ExitClass {
+	switch {
+	case code < 0:
+		return ExitFatal
+
+	case code < 2:
+		return ExitSuccess
+
+	case code < 8:
+		return ExitWarning
+
+	case code < 16:
+		return ExitPartial
+
+	default:
+		return ExitFatal
+	}
+}
@@ -3,9 +3,12 @@ package cmd
 import (
 	"flag"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/ghifari160/migrate/internal/exit"
 	"github.com/ghifari160/migrate/internal/logger"
@@ -16,18 +19,38 @@ type CmdGenerate struct {
 	printFlags bool
 	c          generateConf
 	m          map[string]string
-	src        string
+	srcs       []string
 	dest       string
 	manifest   string
 	log        *logger.Logger
 }
 
 type generateConf struct {
-	overwrite bool
-	relSrc    bool
-	relDest   bool
+	log             logConf
+	overwrite       bool
+	relSrc          bool
+	relDest         bool
+	manifest        manifestConf
+	sort            string
+	reverse         bool
+	depth           int
+	recursive       bool
+	caseInsensitive bool
+	failConflicts   bool
+	fromStdin       bool
+	srcsFile        string
+	null            bool
+	include         patternsFlag
+	exclude         patternsFlag
 }
 
+// Manifest entry sort orders.
+const (
+	SortName  = "name"
+	SortSize  = "size"
+	SortMtime = "mtime"
+)
+
 func NewCmdGenerate() Cmd {
 	return &CmdGenerate{
 		f: NewFlagSet("generate"),
@@ -35,6 +58,8 @@ func NewCmdGenerate() Cmd {
 			overwrite: false,
 			relSrc:    true,
 			relDest:   false,
+			sort:      SortName,
+			depth:     1,
 		},
 	}
 }
@@ -45,6 +70,25 @@ func (c *CmdGenerate) Command(args []string) int {
 	c.f.BoolVar(&c.c.overwrite, "overwrite", c.c.overwrite, "Overwrite manifest.")
 	c.f.BoolVar(&c.c.relSrc, "rel-src", c.c.relSrc, "Relative source path in the manifest.")
 	c.f.BoolVar(&c.c.relDest, "rel-dest", c.c.relDest, "relative destination path in the manifest.")
+	c.f.IntVar(&c.c.depth, "depth", c.c.depth, "Depth of the generated entries. "+
+		"Directories above the depth are scanned, and each of their children gets its own entry.")
+	c.f.BoolVar(&c.c.recursive, "recursive", c.c.recursive, "Scan directories at every depth, "+
+		"generating an entry for each file. Overrides -depth.")
+	c.f.Var(&c.c.include, "include", "Only generate entries for paths matching the pattern, in gitignore syntax. "+
+		"Can be repeated.")
+	c.f.Var(&c.c.exclude, "exclude", "Skip paths matching the pattern, in gitignore syntax. "+
+		"Applied after the patterns of "+IgnoreName+" in SRC. Can be repeated.")
+	c.f.BoolVar(&c.c.caseInsensitive, "case-insensitive", c.c.caseInsensitive, "Detect destination conflicts "+
+		"ignoring case, as on case-insensitive file systems (e.g. NTFS, SMB shares).")
+	c.f.BoolVar(&c.c.failConflicts, "fail-conflicts", c.c.failConflicts, "Fail without writing the manifest "+
+		"if destination conflicts are found.")
+	c.f.BoolVar(&c.c.fromStdin, "from-stdin", c.c.fromStdin, "Read additional sources from stdin, one per line.")
+	c.f.StringVar(&c.c.srcsFile, "sources-file", c.c.srcsFile, "Read additional sources from a file, one per line.")
+	c.f.BoolVar(&c.c.null, "null", c.c.null, "Sources read from stdin or a file are delimited by NUL instead of newline.")
+	c.f.StringVar(&c.c.sort, "sort", c.c.sort, "Manifest entry order (name, size, mtime).")
+	c.f.BoolVar(&c.c.reverse, "reverse", c.c.reverse, "Reverse the manifest entry order.")
+	manifestFlags(c.f, &c.c.manifest)
+	logFlags(c.f, &c.c.log)
 
 	err = c.f.Parse(args)
 	if err != nil {
@@ -52,29 +96,71 @@ func (c *CmdGenerate) Command(args []string) int {
 		return exit.Usage
 	}
 
-	args = c.f.Args()
+	if c.c.depth < 1 {
+		c.printFlags = true
+		return exit.Usage
+	}
 
-	if len(args) < 2 || len(args[0]) < 1 || len(args[1]) < 1 {
+	switch c.c.sort {
+	case SortName, SortSize, SortMtime:
+	default:
+		c.printFlags = true
 		return exit.Usage
 	}
 
-	c.src, err = filepath.Abs(args[0])
-	if err != nil {
-		return exit.NotFound
+	srcs, args, valid := splitSources(c.f.Args(), c.c.fromStdin || len(c.c.srcsFile) > 0)
+	if !valid {
+		return exit.Usage
+	}
+
+	if c.c.fromStdin {
+		listed, err := readSources(os.Stdin, c.c.null)
+		if err != nil {
+			return exit.NotFound
+		}
+		srcs = append(srcs, listed...)
+	}
+
+	if len(c.c.srcsFile) > 0 {
+		file, err := os.Open(c.c.srcsFile)
+		if err != nil {
+			return exit.NotFound
+		}
+
+		listed, err := readSources(file, c.c.null)
+		file.Close()
+		if err != nil {
+			return exit.NotFound
+		}
+		srcs = append(srcs, listed...)
+	}
+
+	if len(srcs) < 1 {
+		return exit.Usage
 	}
 
-	c.dest, err = filepath.Abs(args[1])
+	c.srcs = make([]string, 0, len(srcs))
+	for _, src := range srcs {
+		abs, err := filepath.Abs(src)
+		if err != nil {
+			return exit.NotFound
+		}
+
+		// reintroduce trailing slash
+		c.srcs = append(c.srcs, PreserveTrailingSlash(src, abs))
+	}
+
+	c.dest, err = filepath.Abs(args[0])
 	if err != nil {
 		return exit.NotFound
 	}
 
-	// reintroduce trailing slashes
-	c.src = PreserveTrailingSlash(args[0], c.src)
-	c.dest = PreserveTrailingSlash(args[1], c.dest)
+	// reintroduce trailing slash
+	c.dest = PreserveTrailingSlash(args[0], c.dest)
 
 	c.manifest = ManifestName
-	if len(args) > 2 && len(args[2]) > 0 {
-		c.manifest = args[2]
+	if len(args) > 1 && len(args[1]) > 0 {
+		c.manifest = args[1]
 	}
 
 	c.manifest, err = filepath.Abs(c.manifest)
@@ -82,7 +168,12 @@ func (c *CmdGenerate) Command(args []string) int {
 		return exit.NotFound
 	}
 
-	c.log, err = logger.OpenLogs("logs")
+	if !c.c.log.valid() {
+		c.printFlags = true
+		return exit.Usage
+	}
+
+	c.log, err = openLogs(c.c.log)
 	if err != nil {
 		return exit.LogError
 	}
@@ -98,29 +189,58 @@ func (c *CmdGenerate) Task() int {
 
 	c.m = make(map[string]string)
 
-	status := generate(c.log, c.c, c.m, c.src, c.dest)
-	if status != exit.Norm {
-		return status
-	}
-
-	flag := os.O_CREATE | os.O_WRONLY
-	if !c.c.overwrite {
-		flag |= os.O_APPEND
-	} else {
-		flag |= os.O_TRUNC
+	for _, src := range c.srcs {
+		status := generate(c.log, c.c, c.m, src, c.dest)
+		if status != exit.Norm {
+			return status
+		}
 	}
 
-	c.log.Log(logger.LevelINFO, "Opening manifest file at "+c.manifest)
-	file, err := os.OpenFile(c.manifest, flag, fs.FileMode(0644))
+	conf := c.c.manifest
+	conf.format, err = manifestFormat(c.manifest, conf.format)
 	if err != nil {
 		c.log.Log(logger.LevelError, "Error opening manifest: "+err.Error())
 		return exit.ManifestWrite
 	}
-	defer file.Close()
+
+	x := newManifestIndex(c.c.caseInsensitive)
+
+	if !c.c.overwrite {
+		if s, err := os.Stat(c.manifest); err == nil && s.Size() > 0 {
+			c.log.Log(logger.LevelINFO, "Reading existing manifest at "+c.manifest)
+
+			err = indexManifest(c.log, x, c.manifest, conf)
+			if err != nil {
+				c.log.Log(logger.LevelError, "Error reading manifest: "+err.Error())
+				return exit.ManifestRead
+			}
+		}
+	}
+
+	entries := make([]manifestEntry, 0, len(c.m))
+	conflicts := 0
 
 	rel := filepath.Dir(c.manifest)
-	for src, dest := range c.m {
-		c.log.Log(logger.LevelINFO, "Writing manifest entry for "+src)
+	for _, src := range sortMappings(c.log, c.m, c.c.sort, c.c.reverse) {
+		dest := c.m[src]
+
+		e := rewriteEntry(conf.rewrites, manifestEntry{src: src, dest: dest})
+		if e.rewritten {
+			src, dest = e.src, e.dest
+			c.log.Log(logger.LevelINFO, "Rewrote "+src+" to "+dest+".")
+		}
+
+		dup, other := x.add(manifestEntry{src: src, dest: dest})
+		if dup {
+			c.log.Log(logger.LevelINFO, "Skipping duplicate entry for "+src)
+			continue
+		}
+
+		if other != nil {
+			conflicts++
+			c.log.Log(logger.LevelWARN, "Destination conflict: "+src+" and "+describeEntry(*other)+
+				" both copy to "+entryTarget(src, dest)+".")
+		}
 
 		if c.c.relSrc {
 			relSrc, err := filepath.Rel(rel, src)
@@ -146,10 +266,49 @@ func (c *CmdGenerate) Task() int {
 			dest = PreserveTrailingSlash(dest, relDest)
 		}
 
-		_, err = file.WriteString(src + ManifestSep + dest + "\n")
+		entries = append(entries, manifestEntry{src: src, dest: dest})
+	}
+
+	if conflicts > 0 {
+		msg := fmt.Sprintf("%d destination conflicts found.", conflicts)
+		fmt.Println(msg)
+
+		if c.c.failConflicts {
+			c.log.Log(logger.LevelError, msg+" Manifest not written.")
+			return exit.DestConflict
+		}
+
+		c.log.Log(logger.LevelWARN, msg)
+	}
+
+	flag := os.O_CREATE | os.O_WRONLY
+	if !c.c.overwrite {
+		flag |= os.O_APPEND
+	} else {
+		flag |= os.O_TRUNC
+	}
+
+	c.log.Log(logger.LevelINFO, "Opening "+conf.format+" manifest file at "+c.manifest)
+	file, err := os.OpenFile(c.manifest, flag, fs.FileMode(0644))
+	if err != nil {
+		c.log.Log(logger.LevelError, "Error opening manifest: "+err.Error())
+		return exit.ManifestWrite
+	}
+	defer file.Close()
+
+	w, err := newManifestWriter(file, conf, c.manifest, !c.c.overwrite)
+	if err != nil {
+		c.log.Log(logger.LevelError, "Error preparing manifest: "+err.Error())
+		return exit.ManifestWrite
+	}
+
+	for _, e := range entries {
+		c.log.Log(logger.LevelINFO, "Writing manifest entry for "+e.src)
+
+		err = w.WriteEntry(e)
 		if err != nil {
-			c.log.Log(logger.LevelError, "Unable to create manifest entry for "+src+". Skipping.")
-			c.log.File(src).Log(logger.LevelError, "Error creating manifest entry: "+err.Error())
+			c.log.Log(logger.LevelError, "Unable to create manifest entry for "+e.src+". Skipping.")
+			c.log.File(e.src).Log(logger.LevelError, "Error creating manifest entry: "+err.Error())
 			continue
 		}
 	}
@@ -157,13 +316,84 @@ func (c *CmdGenerate) Task() int {
 	return exit.Norm
 }
 
+// splitSources splits the positional arguments into the sources and the remaining DEST [MANIFEST]
+// arguments.
+// Sources are separated from DEST by --, which may be omitted for a single source.
+// If sources are listed elsewhere, the arguments may consist of DEST [MANIFEST] alone.
+// It reports false if the arguments are invalid.
+func splitSources(args []string, listed bool) ([]string, []string, bool) {
+	var srcs []string
+
+	sep := -1
+	for i, arg := range args {
+		if arg == "--" {
+			sep = i
+			break
+		}
+	}
+
+	switch {
+	case sep >= 0:
+		srcs, args = args[:sep], args[sep+1:]
+
+	case listed && len(args) < 3:
+		// DEST [MANIFEST] only
+	case len(args) > 0:
+		srcs, args = args[:1], args[1:]
+	}
+
+	if len(args) < 1 || len(args) > 2 || len(args[0]) < 1 {
+		return nil, nil, false
+	}
+
+	for _, src := range srcs {
+		if len(src) < 1 {
+			return nil, nil, false
+		}
+	}
+
+	// detach the sources from args, as listed sources are appended to them
+	srcs = append([]string(nil), srcs...)
+
+	return srcs, args, true
+}
+
+// readSources reads the sources listed in r, delimited by newline or NUL.
+// Blank sources are ignored.
+func readSources(r io.Reader, null bool) ([]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	delim := "\n"
+	if null {
+		delim = "\x00"
+	}
+
+	srcs := make([]string, 0)
+	for _, src := range strings.Split(string(data), delim) {
+		if !null {
+			src = strings.TrimSuffix(src, "\r")
+		}
+
+		if len(src) > 0 {
+			srcs = append(srcs, src)
+		}
+	}
+
+	return srcs, nil
+}
+
 // generate generates a source->dest mapping for the given src and dest and returns status code.
-// If src is a dir, it is scanned and the mappings will be for its children instead.
-// This is NOT done recursively.
-// It is up to the copying utility to handle directories.
+// If src is a dir with a trailing slash, its children are at depth 1.
+// Otherwise, src itself is at depth 1.
+// Directories above the configured depth are scanned, and the mappings will be for their children
+// instead, mirroring their relative path under dest.
+// Mirrored destinations have a trailing slash, so the children are always copied into them.
+// It is up to the copying utility to handle directories at the configured depth.
 func generate(log *logger.Logger, config generateConf, m map[string]string, src, dest string) int {
 	var err error
-	var files []string
 
 	log.Log(logger.LevelINFO, "Generating mapping for "+src)
 
@@ -175,47 +405,152 @@ func generate(log *logger.Logger, config generateConf, m map[string]string, src,
 		return exit.ManifestWrite
 	}
 
-	var isDot bool
-	if s.IsDir() {
-		isDot, err = isCwd(src)
-		if err != nil {
-			log.Log(logger.LevelError, "Error checking directory "+src)
-			log.File(src).Log(logger.LevelError, "Error checking directory: "+err.Error())
+	maxDepth := config.depth
+	if config.recursive {
+		maxDepth = 0
+	}
 
-			return exit.ManifestWrite
+	if !s.IsDir() {
+		if !hasTrailingSlash(src) {
+			log.Log(logger.LevelINFO, "Found "+src)
+			m[src] = dest
 		}
 
-		if hasTrailingSlash(src) || isDot {
-			f, err := os.ReadDir(src)
-			if err != nil {
-				log.Log(logger.LevelError, "Error reading directory contents for "+src)
-				log.File(src).Log(logger.LevelError, "Error reading directory: "+err.Error())
+		return exit.Norm
+	}
 
-				return exit.ManifestWrite
-			}
+	isDot, err := isCwd(src)
+	if err != nil {
+		log.Log(logger.LevelError, "Error checking directory "+src)
+		log.File(src).Log(logger.LevelError, "Error checking directory: "+err.Error())
+
+		return exit.ManifestWrite
+	}
+
+	filter, err := newPathFilter(filepath.Clean(src), config)
+	if err != nil {
+		log.Log(logger.LevelError, "Error reading "+IgnoreName+" for "+src)
+		log.File(src).Log(logger.LevelError, "Error reading "+IgnoreName+": "+err.Error())
+
+		return exit.ManifestWrite
+	}
 
-			files = make([]string, 0, len(f))
-			for _, file := range f {
-				files = append(files, filepath.Join(src, file.Name()))
+	if hasTrailingSlash(src) || isDot {
+		return generateDir(log, m, filter, src, dest, 1, maxDepth)
+	}
+
+	if maxDepth == 1 {
+		log.Log(logger.LevelINFO, "Found "+src)
+		m[src] = dest
+
+		return exit.Norm
+	}
+
+	// trailing slash keeps the nested destination a directory
+	return generateDir(log, m, filter, src, filepath.Join(dest, filepath.Base(filepath.Clean(src)))+PathSep, 2, maxDepth)
+}
+
+// generateDir generates a source->dest mapping for each child of dir, which are at the given
+// depth, and returns status code.
+// Child directories are scanned while depth is below maxDepth, or at every depth if maxDepth is 0.
+// Empty directories are mapped as is, so they are still created at the destination.
+// Paths rejected by the filter are skipped, but directories are scanned whether they are included
+// or not.
+func generateDir(log *logger.Logger, m map[string]string, filter *pathFilter, dir, dest string, depth, maxDepth int) int {
+	f, err := os.ReadDir(dir)
+	if err != nil {
+		log.Log(logger.LevelError, "Error reading directory contents for "+dir)
+		log.File(dir).Log(logger.LevelError, "Error reading directory: "+err.Error())
+
+		return exit.ManifestWrite
+	}
+
+	for _, file := range f {
+		path := filepath.Join(dir, file.Name())
+
+		if filter.excluded(path, file.IsDir()) {
+			log.Log(logger.LevelINFO, "Skipping excluded "+path)
+			continue
+		}
+
+		if file.IsDir() && (maxDepth < 1 || depth < maxDepth) && !isEmptyDir(path) {
+			// trailing slash keeps the nested destination a directory
+			status := generateDir(log, m, filter, path, filepath.Join(dest, file.Name())+PathSep, depth+1, maxDepth)
+			if status != exit.Norm {
+				return status
 			}
+
+			continue
+		}
+
+		if !filter.included(path, file.IsDir()) {
+			log.Log(logger.LevelINFO, "Skipping "+path+" not matching any -include pattern")
+			continue
 		}
+
+		log.Log(logger.LevelINFO, "Found "+path)
+		m[path] = dest
 	}
 
-	if len(files) < 1 && !hasTrailingSlash(src) {
-		files = make([]string, 1)
-		files[0] = src
+	return exit.Norm
+}
+
+// isEmptyDir checks if dir is an empty directory.
+func isEmptyDir(dir string) bool {
+	f, err := os.ReadDir(dir)
+	return err == nil && len(f) < 1
+}
+
+// sortMappings returns the sources of the mappings sorted by name, size, or modification time.
+// Sizes and modification times are ascending, with ties sorted by name.
+// Sources that cannot be examined sort as if they were empty and unmodified.
+func sortMappings(log *logger.Logger, m map[string]string, by string, reverse bool) []string {
+	srcs := make([]string, 0, len(m))
+	for src := range m {
+		srcs = append(srcs, src)
 	}
 
-	for _, file := range files {
-		log.Log(logger.LevelINFO, "Found "+file)
-		m[file] = dest
+	keys := make(map[string]int64, len(srcs))
+
+	for _, src := range srcs {
+		switch by {
+		case SortSize:
+			size, err := pathSize(src)
+			if err != nil {
+				log.Log(logger.LevelWARN, "Error sizing "+src+": "+err.Error())
+			}
+			keys[src] = size
+
+		case SortMtime:
+			s, err := os.Lstat(src)
+			if err != nil {
+				log.Log(logger.LevelWARN, "Error checking modification time of "+src+": "+err.Error())
+				continue
+			}
+			keys[src] = s.ModTime().UnixNano()
+		}
 	}
 
-	return exit.Norm
+	sort.Slice(srcs, func(i, j int) bool {
+		a, b := srcs[i], srcs[j]
+		if reverse {
+			a, b = b, a
+		}
+
+		if keys[a] != keys[b] {
+			return keys[a] < keys[b]
+		}
+
+		return a < b
+	})
+
+	return srcs
 }
 
 func (c *CmdGenerate) Usage() string {
-	usage := "  migrate generate SRC DEST [MANIFEST]\n"
+	usage := "  migrate generate SRC DEST [MANIFEST]\n" +
+		"  migrate generate SRC... -- DEST [MANIFEST]\n" +
+		"  migrate generate -from-stdin|-sources-file FILE [SRC... --] DEST [MANIFEST]\n"
 
 	if c.printFlags {
 		usage += "\nFLAGS:\n\n" + PrintDefaults(c.f)
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ghifari160/migrate/internal/exit"
+	"github.com/ghifari160/migrate/internal/logger"
+)
+
+// TestGenerateDepthCopiesIntoNestedDest generates mappings below depth 1 and copies them, checking
+// that files sharing a nested destination are all copied into it.
+func TestGenerateDepthCopiesIntoNestedDest(t *testing.T) {
+	dir := t.TempDir()
+
+	src := filepath.Join(dir, "src")
+	out := filepath.Join(dir, "out")
+
+	for _, name := range []string{"a.txt", "b.txt"} {
+		path := filepath.Join(src, "sub", name)
+
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = os.WriteFile(path, []byte(name), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	log, err := logger.OpenLogs(filepath.Join(dir, "logs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+
+	m := make(map[string]string)
+	config := generateConf{depth: 2}
+
+	status := generate(log, config, m, src+PathSep, out+PathSep)
+	if status != exit.Norm {
+		t.Fatalf("generate returned status %d", status)
+	}
+
+	if len(m) != 2 {
+		t.Fatalf("generated %d mappings, want 2: %v", len(m), m)
+	}
+
+	for s, d := range m {
+		if !hasTrailingSlash(d) {
+			t.Errorf("destination %s of %s has no trailing slash", d, s)
+		}
+
+		err = copyBuiltin(s, d)
+		if err != nil {
+			t.Fatalf("copying %s to %s: %v", s, d, err)
+		}
+	}
+
+	for _, name := range []string{"a.txt", "b.txt"} {
+		data, err := os.ReadFile(filepath.Join(out, "sub", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != name {
+			t.Errorf("%s holds %q, want %q", name, data, name)
+		}
+	}
+}
+
+// TestGenerateSkipsIgnoreFile checks that the ignore file of the source root is not mapped, unless
+// a rule includes it.
+func TestGenerateSkipsIgnoreFile(t *testing.T) {
+	dir := t.TempDir()
+
+	src := filepath.Join(dir, "src")
+	out := filepath.Join(dir, "out")
+
+	err := os.MkdirAll(src, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range map[string]string{IgnoreName: "*.tmp\n", "a": "a", "b.tmp": "b"} {
+		err = os.WriteFile(filepath.Join(src, name), []byte(data), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	log, err := logger.OpenLogs(filepath.Join(dir, "logs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+
+	m := make(map[string]string)
+
+	status := generate(log, generateConf{depth: 1}, m, src+PathSep, out+PathSep)
+	if status != exit.Norm {
+		t.Fatalf("generate returned status %d", status)
+	}
+
+	if _, found := m[filepath.Join(src, "a")]; len(m) != 1 || !found {
+		t.Errorf("generated %v, want only %s", m, filepath.Join(src, "a"))
+	}
+
+	m = make(map[string]string)
+	config := generateConf{depth: 1}
+	config.exclude.Set("!" + IgnoreName)
+
+	generate(log, config, m, src+PathSep, out+PathSep)
+	if _, found := m[filepath.Join(src, IgnoreName)]; !found {
+		t.Errorf("generated %v without %s negated", m, IgnoreName)
+	}
+}
@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghifari160/migrate/internal/logger"
+)
+
+// globRecursive matches zero or more directories in a glob pattern.
+const globRecursive = "**"
+
+// hasGlobMeta checks if path contains any glob metacharacters.
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// isGlob checks if path is a glob pattern.
+// Paths containing glob metacharacters that exist as is are not patterns.
+func isGlob(path string) bool {
+	if !hasGlobMeta(path) {
+		return false
+	}
+
+	_, err := os.Lstat(filepath.Clean(path))
+	return err != nil
+}
+
+// expandGlob returns the paths matching the glob pattern in lexical order.
+// Each path component is matched with [filepath.Match], with ** matching zero or more directories.
+// Wildcards match hidden files and directories.
+// A pattern with a trailing slash only matches directories, which keep the trailing slash.
+func expandGlob(pattern string) ([]string, error) {
+	dirOnly := hasTrailingSlash(pattern)
+	pattern = filepath.Clean(pattern)
+
+	vol := filepath.VolumeName(pattern)
+	rest := pattern[len(vol):]
+
+	root := "."
+	if len(vol) > 0 {
+		root = vol
+	}
+	if len(rest) > 0 && os.IsPathSeparator(rest[0]) {
+		root = vol + string(filepath.Separator)
+	}
+
+	parts := strings.Split(strings.Trim(rest, string(filepath.Separator)), string(filepath.Separator))
+	for _, part := range parts {
+		if _, err := filepath.Match(part, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	var found []string
+	err := globDir(root, parts, dirOnly, &found)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(found))
+	matches := make([]string, 0, len(found))
+	for _, path := range found {
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		if dirOnly {
+			path += string(filepath.Separator)
+		}
+		matches = append(matches, path)
+	}
+
+	return matches, nil
+}
+
+// globDir appends the paths in dir matching the pattern components to matches.
+// Directories that cannot be read do not match.
+func globDir(dir string, parts []string, dirOnly bool, matches *[]string) error {
+	if len(parts) < 1 {
+		if dirOnly && !isDir(dir) {
+			return nil
+		}
+
+		*matches = append(*matches, dir)
+		return nil
+	}
+
+	part, rest := parts[0], parts[1:]
+
+	if !hasGlobMeta(part) {
+		path := filepath.Join(dir, part)
+		if _, err := os.Lstat(path); err != nil {
+			return nil
+		}
+
+		return globDir(path, rest, dirOnly, matches)
+	}
+
+	if part == globRecursive {
+		err := globDir(dir, rest, dirOnly, matches)
+		if err != nil {
+			return err
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+
+		if part == globRecursive {
+			// symbolic links are not followed to avoid cycles
+			if entry.IsDir() {
+				err = globDir(path, parts, dirOnly, matches)
+			} else if len(rest) < 1 && !dirOnly {
+				*matches = append(*matches, path)
+			}
+		} else if matched, _ := filepath.Match(part, entry.Name()); matched {
+			if len(rest) < 1 || isDir(path) {
+				err = globDir(path, rest, dirOnly, matches)
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// globBase returns the leading components of the glob pattern that contain no metacharacters.
+func globBase(pattern string) string {
+	base := filepath.Clean(pattern)
+	for hasGlobMeta(base) {
+		base = filepath.Dir(base)
+	}
+
+	return base
+}
+
+// globDest returns the destination of a match of the glob pattern of an entry copied to dest.
+// Matches keep their path relative to the base of the pattern below dest, so that matches of the
+// same name are not copied onto each other.
+// A match with a trailing slash has its contents copied to that path, while other matches are
+// copied into its parent directory.
+func globDest(pattern, match, dest string) (string, error) {
+	rel, err := filepath.Rel(globBase(pattern), filepath.Clean(match))
+	if err != nil {
+		return "", err
+	}
+
+	if hasTrailingSlash(match) {
+		return filepath.Join(dest, rel) + PathSep, nil
+	}
+
+	return filepath.Join(dest, filepath.Dir(rel)) + PathSep, nil
+}
+
+// isDir checks if path is a directory, following symbolic links.
+func isDir(path string) bool {
+	s, err := os.Stat(path)
+	return err == nil && s.IsDir()
+}
+
+// logExpansion logs the glob pattern the source of the manifest entry was expanded from, if any.
+func logExpansion(log *logger.Logger, e manifestEntry) {
+	if len(e.pattern) < 1 {
+		return
+	}
+
+	log.LogWith(logger.LevelINFO, fmt.Sprintf("Expanded %s at %s to %s => %s.",
+		e.pattern, e.location(), e.src, e.dest), logger.Fields{Line: e.line, Src: e.src, Dest: e.dest})
+}
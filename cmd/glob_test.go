@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGlobDest(t *testing.T) {
+	base := filepath.FromSlash("/data")
+	dest := filepath.FromSlash("/dest")
+
+	tests := []struct {
+		pattern string
+		match   string
+		want    string
+	}{
+		{pattern: "/data/**/*.pst", match: "/data/alice/outlook.pst", want: "/dest/alice/"},
+		{pattern: "/data/**/*.pst", match: "/data/outlook.pst", want: "/dest/"},
+		{pattern: "/data/*/reports/", match: "/data/p1/reports/", want: "/dest/p1/reports/"},
+		{pattern: "/data/p*", match: "/data/p1", want: "/dest/"},
+	}
+
+	for _, test := range tests {
+		pattern := filepath.FromSlash(test.pattern)
+		match := filepath.FromSlash(test.match)
+
+		got, err := globDest(pattern, match, dest)
+		if err != nil {
+			t.Errorf("globDest(%q, %q) returned error: %v", pattern, match, err)
+			continue
+		}
+
+		if want := filepath.FromSlash(test.want); got != want {
+			t.Errorf("globDest(%q, %q) = %q, want %q", pattern, match, got, want)
+		}
+	}
+
+	if got := globBase(filepath.Join(base, "*", "x", "**")); got != base {
+		t.Errorf("globBase = %q, want %q", got, base)
+	}
+}
+
+// TestGlobExpansionKeepsSameNames expands two files of the same name into one destination and
+// checks that both are copied.
+func TestGlobExpansionKeepsSameNames(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, user := range []string{"alice", "bob"} {
+		path := filepath.Join(dir, "home", user, "outlook.pst")
+
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = os.WriteFile(path, []byte(user), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	line := filepath.Join(dir, "home", "**", "*.pst") + ManifestSep + filepath.Join(dir, "pst") + PathSep
+	m := newManifestReader(strings.NewReader(line+"\n"), nil, manifestConf{format: FormatText})
+
+	for {
+		e, err := readManifestEntry(m)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = copyBuiltin(e.src, e.dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, user := range []string{"alice", "bob"} {
+		data, err := os.ReadFile(filepath.Join(dir, "pst", user, "outlook.pst"))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if string(data) != user {
+			t.Errorf("copy of %s's file holds %q", user, data)
+		}
+	}
+}
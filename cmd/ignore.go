@@ -0,0 +1,260 @@
+package cmd
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IgnoreName is the name of the file listing the paths ignored by generate, in gitignore syntax.
+// It is read from the root of the source, and is itself skipped unless a pattern negates it.
+const IgnoreName = ".migrateignore"
+
+// ignoreRule is a path pattern in gitignore syntax.
+// Patterns without a slash match the name of a path at any depth, while other patterns match the
+// path relative to the source root.
+// A trailing slash only matches directories, and a leading ! negates the pattern.
+type ignoreRule struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// parseIgnoreRule parses the pattern.
+// It reports false for blank patterns and comments.
+func parseIgnoreRule(pattern string) (ignoreRule, bool, error) {
+	pattern = strings.TrimRight(pattern, " \t\r")
+	if len(pattern) < 1 || strings.HasPrefix(pattern, "#") {
+		return ignoreRule{}, false, nil
+	}
+
+	r := ignoreRule{}
+
+	if strings.HasPrefix(pattern, "!") {
+		r.negate = true
+		pattern = pattern[1:]
+	} else if strings.HasPrefix(pattern, `\!`) || strings.HasPrefix(pattern, `\#`) {
+		pattern = pattern[1:]
+	}
+
+	if strings.HasSuffix(pattern, "/") {
+		r.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+
+	if len(pattern) < 1 {
+		return ignoreRule{}, false, nil
+	}
+
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	expr := globRegexp(pattern)
+	if !anchored {
+		expr = "(.*/)?" + expr
+	}
+
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return ignoreRule{}, false, err
+	}
+
+	r.re = re
+
+	return r, true, nil
+}
+
+// globRegexp translates the gitignore pattern into a regular expression.
+func globRegexp(pattern string) string {
+	var expr strings.Builder
+
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			expr.WriteString("(.*/)?")
+			i += 2
+
+		case pattern[i:] == "/**":
+			expr.WriteString("/.*")
+			i += 2
+
+		case strings.HasPrefix(pattern[i:], globRecursive):
+			expr.WriteString(".*")
+			i++
+
+		case c == '*':
+			expr.WriteString("[^/]*")
+
+		case c == '?':
+			expr.WriteString("[^/]")
+
+		case c == '\\' && i+1 < len(pattern):
+			expr.WriteString(regexp.QuoteMeta(pattern[i+1 : i+2]))
+			i++
+
+		case c == '[':
+			end := strings.Index(pattern[i+1:], "]")
+			if end < 0 {
+				expr.WriteString(`\[`)
+				continue
+			}
+
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+
+			expr.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return expr.String()
+}
+
+// match checks if the rule matches the slash-separated path relative to the source root.
+func (r ignoreRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+
+	return r.re.MatchString(rel)
+}
+
+// parseIgnoreFile parses the rules of an ignore file.
+// Missing ignore files have no rules.
+func parseIgnoreFile(path string) ([]ignoreRule, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+	defer file.Close()
+
+	rules := make([]ignoreRule, 0)
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		rule, ok, err := parseIgnoreRule(scanner.Text())
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules, scanner.Err()
+}
+
+// patternsFlag collects repeated gitignore patterns.
+// It implements flag.Value.
+type patternsFlag []string
+
+func (p *patternsFlag) String() string {
+	if p == nil {
+		return ""
+	}
+
+	return strings.Join(*p, ", ")
+}
+
+func (p *patternsFlag) Set(pattern string) error {
+	_, _, err := parseIgnoreRule(pattern)
+	if err != nil {
+		return err
+	}
+
+	*p = append(*p, pattern)
+
+	return nil
+}
+
+// rules parses the patterns.
+func (p patternsFlag) rules() []ignoreRule {
+	rules := make([]ignoreRule, 0, len(p))
+
+	for _, pattern := range p {
+		if rule, ok, _ := parseIgnoreRule(pattern); ok {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules
+}
+
+// pathFilter decides which paths under a source root are mapped.
+type pathFilter struct {
+	root    string
+	exclude []ignoreRule
+	include []ignoreRule
+}
+
+// newPathFilter returns the filter for the paths under root.
+// The rules of the ignore file in root are applied before the -exclude patterns.
+func newPathFilter(root string, config generateConf) (*pathFilter, error) {
+	rules, err := parseIgnoreFile(filepath.Join(root, IgnoreName))
+	if err != nil {
+		return nil, err
+	}
+
+	return &pathFilter{
+		root:    root,
+		exclude: append(rules, config.exclude.rules()...),
+		include: config.include.rules(),
+	}, nil
+}
+
+// rel returns the slash-separated path relative to the root.
+func (f *pathFilter) rel(path string) string {
+	rel, err := filepath.Rel(f.root, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+
+	return filepath.ToSlash(rel)
+}
+
+// excluded checks if path is excluded.
+// The last matching rule decides.
+// The ignore file in the root is excluded unless a rule includes it.
+func (f *pathFilter) excluded(path string, isDir bool) bool {
+	rel := f.rel(path)
+	excluded := rel == IgnoreName && !isDir
+
+	for _, rule := range f.exclude {
+		if rule.match(rel, isDir) {
+			excluded = !rule.negate
+		}
+	}
+
+	return excluded
+}
+
+// included checks if path is included.
+// Every path is included if there are no -include patterns.
+func (f *pathFilter) included(path string, isDir bool) bool {
+	if len(f.include) < 1 {
+		return true
+	}
+
+	rel := f.rel(path)
+	included := false
+
+	for _, rule := range f.include {
+		if rule.match(rel, isDir) {
+			included = !rule.negate
+		}
+	}
+
+	return included
+}
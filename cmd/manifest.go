@@ -0,0 +1,970 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ghifari160/migrate/internal/exit"
+	"github.com/ghifari160/migrate/internal/logger"
+)
+
+// ManifestHeader marks the first line of a manifest in the quoted manifest syntax.
+//
+// Version 1 manifests have no header. Each line is a source path and a destination path separated
+// by ManifestSep, taken literally.
+//
+// Version 2 manifests start with ManifestHeader. A path may be enclosed in double quotes, in which
+// case it is unquoted with Go string literal rules (e.g. \" for a double quote, \n for a newline).
+// Quoting allows paths to contain ManifestSep, newlines, and leading or trailing whitespace.
+// Unquoted paths are taken literally.
+//
+// In both versions, blank lines and lines starting with # are ignored, and lines starting with @
+// are directives:
+//
+//	@include PATH     reads the entries of the text manifest at PATH, relative to this manifest
+//	@set NAME=VALUE   defines the variable NAME, referenced as ${NAME} in the paths that follow
+//
+// Variable references are expanded in the paths of every manifest format.
+// Variables are looked up from -var flags, @set directives, then the environment.
+// $${ escapes a literal ${.
+//
+// Sources may be glob patterns, with ** matching zero or more directories.
+// Patterns are expanded when the manifest is read, into an entry for each matching path.
+// Each match is copied below the destination at its path relative to the leading components of the
+// pattern without metacharacters, so that the matches keep their tree structure.
+const ManifestHeader = "#migrate-manifest v2"
+
+const (
+	manifestComment   = "#"
+	manifestDirective = "@"
+)
+
+const (
+	manifestV1 = 1
+	manifestV2 = 2
+)
+
+// Manifest formats.
+const (
+	FormatAuto  = "auto"
+	FormatText  = "text"
+	FormatJSONL = "jsonl"
+	FormatYAML  = "yaml"
+	FormatCSV   = "csv"
+)
+
+// Default CSV manifest column names.
+const (
+	CSVSrcColumn  = "source"
+	CSVDestColumn = "destination"
+)
+
+// manifestConf configures how manifests are read and written.
+type manifestConf struct {
+	format string
+
+	// csvSrc and csvDest are the names of the CSV manifest columns holding the paths.
+	csvSrc  string
+	csvDest string
+
+	// vars holds the variables set on the command line.
+	vars varsFlag
+	// rewrites holds the rewrite rules applied to every entry.
+	rewrites *rewriteRules
+}
+
+// manifestEntry is a single source->dest mapping read from the manifest.
+// Structured manifest formats may carry additional per-entry fields.
+type manifestEntry struct {
+	line int
+	src  string
+	dest string
+
+	// file is the included manifest the entry was read from, empty for the manifest itself.
+	file string
+	// seq is the position of the entry in reading order.
+	seq int
+
+	// argv overrides the copying utility arguments if not nil.
+	argv []string
+	// size is the expected size of the copy if not nil.
+	size *int64
+	// checksum is the expected checksum of the copy, formatted as HASH:HEX or HEX.
+	checksum string
+	// pattern is the glob pattern the source was expanded from, empty if src is not expanded.
+	pattern string
+	// rewritten is true if dest was rewritten by a rewrite rule.
+	rewritten bool
+}
+
+// manifestRecord is a manifest entry as stored in structured manifest formats.
+type manifestRecord struct {
+	Src      string  `json:"src"`
+	Dest     string  `json:"dest"`
+	Args     *string `json:"args,omitempty"`
+	Size     *int64  `json:"size,omitempty"`
+	Checksum string  `json:"checksum,omitempty"`
+}
+
+// manifestReader reads manifest entries line by line.
+type manifestReader struct {
+	r       *bufio.Reader
+	close   func() error
+	name    string
+	conf    manifestConf
+	version int
+	line    int
+
+	// parents holds the state of the manifests including the one being read.
+	parents []manifestFile
+	// vars holds the variables defined by @set directives.
+	vars map[string]string
+	// entries counts the entries read so far.
+	entries int
+
+	// csv reads CSV manifests, whose columns are mapped by csvColumns.
+	csv        *csv.Reader
+	csvColumns map[string]int
+
+	// pending holds a line that has been read ahead.
+	pending     string
+	pendingLine int
+	hasPending  bool
+
+	// expanded holds the entries expanded from a glob pattern that have yet to be returned.
+	expanded []manifestEntry
+	// base is the directory relative paths are resolved against, instead of the working directory.
+	base string
+}
+
+// manifestFile is the reading state of a single text manifest file.
+type manifestFile struct {
+	r       *bufio.Reader
+	close   func() error
+	name    string
+	version int
+	line    int
+}
+
+func newManifestReader(r io.Reader, closeFn func() error, conf manifestConf) *manifestReader {
+	return &manifestReader{
+		r:       bufio.NewReader(r),
+		close:   closeFn,
+		conf:    conf,
+		version: manifestV1,
+		line:    1,
+		vars:    make(map[string]string),
+	}
+}
+
+// manifestFormat returns the format of the manifest at path.
+// If format is FormatAuto or empty, the format is detected from the file extension.
+func manifestFormat(path, format string) (string, error) {
+	switch strings.ToLower(format) {
+	case FormatText, FormatJSONL, FormatYAML, FormatCSV:
+		return strings.ToLower(format), nil
+
+	case FormatAuto, "":
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".jsonl", ".ndjson", ".json":
+			return FormatJSONL, nil
+
+		case ".csv":
+			return FormatCSV, nil
+
+		case ".yaml", ".yml":
+			return FormatYAML, nil
+
+		default:
+			return FormatText, nil
+		}
+
+	default:
+		return "", errors.New("unknown manifest format " + format)
+	}
+}
+
+// Close closes the underlying manifest, including any included manifests being read.
+func (m *manifestReader) Close() error {
+	for m.popInclude() {
+	}
+
+	return m.close()
+}
+
+// errAt returns a manifest error at the given line of the manifest being read.
+// Errors in included manifests report the included manifest.
+func (m *manifestReader) errAt(lineN int, msg string) error {
+	if len(m.parents) > 0 {
+		return newManifestFileErr(m.name, lineN, msg)
+	}
+
+	return newManifestErr(lineN, msg)
+}
+
+// includeFile returns the name of the included manifest being read, or an empty string.
+func (m *manifestReader) includeFile() string {
+	if len(m.parents) > 0 {
+		return m.name
+	}
+
+	return ""
+}
+
+// pushInclude starts reading the text manifest at path, relative to the manifest being read.
+// Reading resumes from the including manifest once the included manifest has been read.
+func (m *manifestReader) pushInclude(path string) error {
+	if !filepath.IsAbs(path) && len(m.name) > 0 {
+		path = filepath.Join(filepath.Dir(m.name), path)
+	}
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	if path == m.name {
+		return errors.New("include cycle")
+	}
+
+	for _, parent := range m.parents {
+		if parent.name == path {
+			return errors.New("include cycle")
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	m.parents = append(m.parents, manifestFile{
+		r:       m.r,
+		close:   m.close,
+		name:    m.name,
+		version: m.version,
+		line:    m.line,
+	})
+
+	m.r = bufio.NewReader(file)
+	m.close = file.Close
+	m.name = path
+	m.version = manifestV1
+	m.line = 1
+
+	return nil
+}
+
+// popInclude closes the included manifest being read and resumes reading its including manifest.
+// It returns false if the manifest being read is not an included manifest.
+func (m *manifestReader) popInclude() bool {
+	if len(m.parents) < 1 {
+		return false
+	}
+
+	m.close()
+
+	parent := m.parents[len(m.parents)-1]
+	m.parents = m.parents[:len(m.parents)-1]
+
+	m.r = parent.r
+	m.close = parent.close
+	m.name = parent.name
+	m.version = parent.version
+	m.line = parent.line
+
+	return true
+}
+
+// resolvePath resolves the relative path against base.
+// Absolute paths are returned as is.
+func resolvePath(base, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+
+	return PreserveTrailingSlash(path, filepath.Join(base, path))
+}
+
+// normPaths normalizes paths by converting them to absolute paths.
+// Trailing slashes are reintroduced into the paths after normalizations.
+func normPaths(src, dest string) (string, string, bool) {
+	var err error
+
+	aSrc, err := filepath.Abs(src)
+	if err != nil {
+		return "", "", false
+	}
+
+	aDest, err := filepath.Abs(dest)
+	if err != nil {
+		return "", "", false
+	}
+
+	// reintroduce trailing slashes
+	src = PreserveTrailingSlash(src, aSrc)
+	dest = PreserveTrailingSlash(dest, aDest)
+
+	return src, dest, true
+}
+
+// openManifestArgs opens the manifest described by the positional arguments and returns the exit
+// status.
+// The arguments are either SRC DEST, a single MANIFEST, or none, in which case ManifestName is read.
+// The manifest is read as configured.
+func openManifestArgs(log *logger.Logger, args []string, conf manifestConf) (*manifestReader, int) {
+	var manifest, src, dest string
+	var m *manifestReader
+	var err error
+
+	if len(args) < 1 {
+		manifest = ManifestName
+	} else if len(args) < 2 {
+		if len(args[0]) < 1 {
+			return nil, exit.Usage
+		}
+
+		manifest = args[0]
+	} else {
+		if len(args[0]) < 1 || len(args[1]) < 1 {
+			return nil, exit.Usage
+		}
+
+		src = args[0]
+		dest = args[1]
+	}
+
+	if len(manifest) > 0 {
+		m, err = openManifest(log, manifest, conf)
+	} else {
+		src, dest, norm := normPaths(src, dest)
+		if !norm {
+			log.Log(logger.LevelWARN, "Cannot normalize paths for "+src+" => "+dest+".")
+			return nil, exit.ManifestRead
+		}
+
+		m, err = manifestFromArgs(log, src, dest, conf)
+	}
+
+	if err != nil {
+		log.Log(logger.LevelError, "error reading manifest: "+err.Error())
+		return nil, exit.ManifestRead
+	}
+
+	return m, exit.RDY
+}
+
+// openManifest opens the manifest and creates a manifest reader.
+// If the format is FormatAuto, the format is detected from the file extension.
+func openManifest(log *logger.Logger, manifest string, conf manifestConf) (*manifestReader, error) {
+	var err error
+
+	conf.format, err = manifestFormat(manifest, conf.format)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := filepath.Abs(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := os.Open(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	r := newManifestReader(m, m.Close, conf)
+	r.name = name
+
+	return r, nil
+}
+
+// manifestFromArgs returns a manifest reader from the given src and dest paths.
+func manifestFromArgs(log *logger.Logger, src, dest string, conf manifestConf) (*manifestReader, error) {
+	var buffer bytes.Buffer
+	closeFn := func() error {
+		buffer.Reset()
+
+		return nil
+	}
+
+	line, err := formatManifestEntry(manifestV2, src, dest)
+	if err != nil {
+		return nil, err
+	}
+
+	buffer.WriteString(line)
+
+	conf.format = FormatText
+
+	// The entry is read as version 2 without a header, so that it is reported as line 1.
+	m := newManifestReader(&buffer, closeFn, conf)
+	m.version = manifestV2
+
+	return m, nil
+}
+
+// readLine reads the next whole line of the manifest.
+//
+// readLine reads whole lines from the buffered reader when possible.
+// If the lines are too long for a single read, multiple reads executed until the whole line has
+// been read.
+func (m *manifestReader) readLine() (string, error) {
+	var lineBuilder strings.Builder
+	var lineBuffer []byte
+	var err error
+	isPrefix := true
+
+	for isPrefix {
+		lineBuffer, isPrefix, err = m.r.ReadLine()
+		if err != nil {
+			return "", err
+		}
+
+		lineBuilder.Write(lineBuffer)
+	}
+
+	return lineBuilder.String(), nil
+}
+
+// nextLine returns the next line of the manifest and its line number.
+// Lines that have been read ahead are returned first.
+func (m *manifestReader) nextLine() (string, int, error) {
+	if m.hasPending {
+		m.hasPending = false
+		return m.pending, m.pendingLine, nil
+	}
+
+	line, err := m.readLine()
+	if err != nil {
+		return "", 0, err
+	}
+
+	lineN := m.line
+	m.line++
+
+	return line, lineN, nil
+}
+
+// unreadLine returns the line to the reader, to be returned by the next call to nextLine.
+func (m *manifestReader) unreadLine(line string, lineN int) {
+	m.pending = line
+	m.pendingLine = lineN
+	m.hasPending = true
+}
+
+// readManifestEntry reads and parses the next entry of the manifest.
+// The entry holds the normalized source path and destination path, and its line number.
+// Entries whose source is a glob pattern are expanded into an entry for each matching path, in
+// lexical order, with the destination given by globDest.
+// Patterns matching nothing are reported as manifestErr.
+// The rewrite rules are applied to each entry after expansion.
+func readManifestEntry(m *manifestReader) (manifestEntry, error) {
+	if len(m.expanded) > 0 {
+		e := m.expanded[0]
+		m.expanded = m.expanded[1:]
+
+		return rewriteEntry(m.conf.rewrites, e), nil
+	}
+
+	e, err := readFormatEntry(m)
+	if err != nil {
+		return e, err
+	}
+
+	if !isGlob(e.src) {
+		return rewriteEntry(m.conf.rewrites, e), nil
+	}
+
+	matches, err := expandGlob(e.src)
+	if err != nil {
+		return manifestEntry{}, m.errAt(e.line, "invalid pattern "+e.src+": "+err.Error())
+	}
+
+	if len(matches) < 1 {
+		return manifestEntry{}, m.errAt(e.line, "no match for pattern "+e.src)
+	}
+
+	dests := make([]string, len(matches))
+	for i, match := range matches {
+		dests[i], err = globDest(e.src, match, e.dest)
+		if err != nil {
+			return manifestEntry{}, m.errAt(e.line, "cannot map "+match+" into "+e.dest+": "+err.Error())
+		}
+	}
+
+	e.pattern = e.src
+	for i, match := range matches {
+		if i > 0 {
+			m.entries++
+			e.seq = m.entries
+		}
+
+		e.src = match
+		e.dest = dests[i]
+		m.expanded = append(m.expanded, e)
+	}
+
+	return readManifestEntry(m)
+}
+
+// readFormatEntry reads the next entry from the manifest according to its format.
+func readFormatEntry(m *manifestReader) (manifestEntry, error) {
+	switch m.conf.format {
+	case FormatJSONL:
+		return readJSONLEntry(m)
+
+	case FormatCSV:
+		return readCSVEntry(m)
+
+	case FormatYAML:
+		return readYAMLEntry(m)
+
+	default:
+		return readTextEntry(m)
+	}
+}
+
+// readTextEntry reads and parses the next entry of a text manifest.
+// The manifest version is detected from its first line.
+// Comments and blank lines are skipped, and directives are applied as they are read.
+func readTextEntry(m *manifestReader) (manifestEntry, error) {
+	for {
+		line, lineN, err := m.nextLine()
+		if err != nil {
+			if errors.Is(err, io.EOF) && m.popInclude() {
+				continue
+			}
+
+			return manifestEntry{}, err
+		}
+
+		if lineN == 1 && line == ManifestHeader {
+			m.version = manifestV2
+			continue
+		}
+
+		trimmed := strings.TrimLeftFunc(line, unicode.IsSpace)
+		if len(trimmed) < 1 || strings.HasPrefix(trimmed, manifestComment) {
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, manifestDirective) {
+			err = readDirective(m, lineN, strings.TrimSpace(trimmed))
+			if err != nil {
+				return manifestEntry{}, err
+			}
+
+			continue
+		}
+
+		var mapping []string
+		if m.version == manifestV2 {
+			mapping, err = splitQuotedFields(line)
+			if err != nil {
+				return manifestEntry{}, m.errAt(lineN, "syntax error: "+err.Error())
+			}
+		} else {
+			mapping = strings.Split(line, ManifestSep)
+		}
+
+		if len(mapping) < 2 {
+			return manifestEntry{}, m.errAt(lineN, "syntax error")
+		}
+
+		return newManifestEntry(m, lineN, manifestRecord{Src: mapping[0], Dest: mapping[1]})
+	}
+}
+
+// readDirective applies the directive of a text manifest.
+func readDirective(m *manifestReader, lineN int, directive string) error {
+	name, arg := directive, ""
+	if i := strings.IndexFunc(directive, unicode.IsSpace); i >= 0 {
+		name, arg = directive[:i], strings.TrimSpace(directive[i:])
+	}
+
+	switch name {
+	case manifestDirective + "include":
+		if len(arg) < 1 {
+			return m.errAt(lineN, "syntax error: missing include path")
+		}
+
+		path := arg
+		if m.version == manifestV2 && strings.HasPrefix(arg, `"`) {
+			var err error
+
+			path, err = strconv.Unquote(arg)
+			if err != nil {
+				return m.errAt(lineN, "syntax error: invalid quoted include path")
+			}
+		}
+
+		err := m.pushInclude(path)
+		if err != nil {
+			return m.errAt(lineN, "cannot include "+path+": "+err.Error())
+		}
+
+	case manifestDirective + "set":
+		i := strings.Index(arg, "=")
+		if i < 0 {
+			return m.errAt(lineN, "syntax error: expected @set NAME=VALUE")
+		}
+
+		key := strings.TrimSpace(arg[:i])
+		if !isVarName(key) {
+			return m.errAt(lineN, "invalid variable name "+key)
+		}
+
+		m.vars[key] = strings.TrimSpace(arg[i+1:])
+
+	default:
+		return m.errAt(lineN, "unknown directive "+name)
+	}
+
+	return nil
+}
+
+// lookupVar returns the value of the named variable.
+// Variables set on the command line take precedence over variables defined by @set directives,
+// which in turn take precedence over environment variables.
+func (m *manifestReader) lookupVar(name string) (string, bool) {
+	if value, found := m.conf.vars[name]; found {
+		return value, true
+	}
+
+	if value, found := m.vars[name]; found {
+		return value, true
+	}
+
+	return os.LookupEnv(name)
+}
+
+// newManifestEntry validates the manifest record and converts it into a manifest entry.
+// Variable references in the paths are expanded.
+func newManifestEntry(m *manifestReader, lineN int, rec manifestRecord) (manifestEntry, error) {
+	if len(rec.Src) < 1 || len(rec.Dest) < 1 {
+		return manifestEntry{}, m.errAt(lineN, "syntax error")
+	}
+
+	var err error
+
+	rec.Src, err = expandVars(rec.Src, m.lookupVar)
+	if err != nil {
+		return manifestEntry{}, m.errAt(lineN, err.Error())
+	}
+
+	rec.Dest, err = expandVars(rec.Dest, m.lookupVar)
+	if err != nil {
+		return manifestEntry{}, m.errAt(lineN, err.Error())
+	}
+
+	if len(rec.Src) < 1 || len(rec.Dest) < 1 {
+		return manifestEntry{}, m.errAt(lineN, "empty path after variable expansion")
+	}
+
+	if len(m.base) > 0 {
+		rec.Src = resolvePath(m.base, rec.Src)
+		rec.Dest = resolvePath(m.base, rec.Dest)
+	}
+
+	src, dest, norm := normPaths(rec.Src, rec.Dest)
+	if !norm {
+		return manifestEntry{}, m.errAt(lineN, "cannot normalize paths for "+src+" => "+dest)
+	}
+
+	m.entries++
+
+	e := manifestEntry{
+		line:     lineN,
+		file:     m.includeFile(),
+		seq:      m.entries,
+		src:      src,
+		dest:     dest,
+		size:     rec.Size,
+		checksum: rec.Checksum,
+	}
+
+	if rec.Args != nil {
+		argv, err := splitArgs(*rec.Args)
+		if err != nil {
+			return manifestEntry{}, m.errAt(lineN, "invalid args: "+err.Error())
+		}
+
+		e.argv = argv
+	}
+
+	return e, nil
+}
+
+// location describes where the entry was read from.
+func (e manifestEntry) location() string {
+	if len(e.file) > 0 {
+		return fmt.Sprintf("line %d of %s", e.line, e.file)
+	}
+
+	return fmt.Sprintf("line %d", e.line)
+}
+
+// record converts the manifest entry into a manifest record.
+// Variable references in the paths are escaped.
+func (e manifestEntry) record() manifestRecord {
+	rec := manifestRecord{
+		Src:      escapeVars(e.src),
+		Dest:     escapeVars(e.dest),
+		Size:     e.size,
+		Checksum: e.checksum,
+	}
+
+	if e.argv != nil {
+		args := joinArgs(e.argv)
+		rec.Args = &args
+	}
+
+	return rec
+}
+
+// splitQuotedFields splits a version 2 manifest line into its fields.
+// Fields enclosed in double quotes are unquoted, while other fields are taken literally.
+func splitQuotedFields(line string) ([]string, error) {
+	fields := make([]string, 0, 2)
+
+	for {
+		if strings.HasPrefix(line, `"`) {
+			quoted, err := strconv.QuotedPrefix(line)
+			if err != nil {
+				return nil, errors.New("unterminated quoted path")
+			}
+
+			field, err := strconv.Unquote(quoted)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, field)
+
+			line = line[len(quoted):]
+			if len(line) < 1 {
+				return fields, nil
+			}
+
+			if !strings.HasPrefix(line, ManifestSep) {
+				return nil, errors.New("unexpected text after quoted path")
+			}
+			line = line[len(ManifestSep):]
+
+			continue
+		}
+
+		i := strings.Index(line, ManifestSep)
+		if i < 0 {
+			return append(fields, line), nil
+		}
+
+		fields = append(fields, line[:i])
+		line = line[i+len(ManifestSep):]
+	}
+}
+
+// formatManifestEntry formats src and dest as a manifest line of the given version, without the
+// line terminator.
+// Version 2 paths are quoted when needed.
+// Paths that cannot be expressed in version 1 manifests are rejected.
+func formatManifestEntry(version int, src, dest string) (string, error) {
+	src = escapeVars(src)
+	dest = escapeVars(dest)
+
+	if version == manifestV2 {
+		return quoteManifestPath(src) + ManifestSep + quoteManifestPath(dest), nil
+	}
+
+	for _, path := range []string{src, dest} {
+		if strings.Contains(path, ManifestSep) || strings.ContainsAny(path, "\r\n") {
+			return "", fmt.Errorf("path %q cannot be written to a version %d manifest", path, version)
+		}
+	}
+
+	trimmed := strings.TrimLeftFunc(src, unicode.IsSpace)
+	if strings.HasPrefix(trimmed, manifestComment) || strings.HasPrefix(trimmed, manifestDirective) {
+		return "", fmt.Errorf("path %q cannot be written to a version %d manifest", src, version)
+	}
+
+	return src + ManifestSep + dest, nil
+}
+
+// quoteManifestPath quotes the path for version 2 manifests if it cannot be written literally.
+func quoteManifestPath(path string) string {
+	if needsQuoting(path) {
+		return strconv.Quote(path)
+	}
+
+	return path
+}
+
+// needsQuoting checks if the path must be quoted in version 2 manifests.
+func needsQuoting(path string) bool {
+	if len(path) < 1 || strings.HasPrefix(path, `"`) || strings.Contains(path, ManifestSep) {
+		return true
+	}
+
+	if strings.HasPrefix(path, manifestComment) || strings.HasPrefix(path, manifestDirective) {
+		return true
+	}
+
+	if !utf8.ValidString(path) {
+		return true
+	}
+
+	first, _ := utf8.DecodeRuneInString(path)
+	last, _ := utf8.DecodeLastRuneInString(path)
+	if unicode.IsSpace(first) || unicode.IsSpace(last) {
+		return true
+	}
+
+	for _, r := range path {
+		if !unicode.IsPrint(r) && r != ' ' {
+			return true
+		}
+	}
+
+	return false
+}
+
+// manifestWriter writes manifest entries in a manifest format.
+type manifestWriter interface {
+	WriteEntry(e manifestEntry) error
+}
+
+// newManifestWriter creates a manifest writer for the configured format.
+// When appending to the existing manifest at path, entries are written to match it.
+// Otherwise, the header of the format is written first, if any.
+func newManifestWriter(w io.Writer, conf manifestConf, path string, appending bool) (manifestWriter, error) {
+	switch conf.format {
+	case FormatJSONL:
+		return &jsonlManifestWriter{w: w}, nil
+
+	case FormatYAML:
+		return &yamlManifestWriter{w: w}, nil
+
+	case FormatCSV:
+		return newCSVManifestWriter(w, conf, path, appending)
+
+	default:
+		version := 0
+		if appending {
+			var err error
+
+			version, err = manifestVersion(path)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// version 0 marks a new manifest
+		if version == 0 {
+			version = manifestV2
+
+			_, err := io.WriteString(w, ManifestHeader+"\n")
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return &textManifestWriter{w: w, version: version}, nil
+	}
+}
+
+// textManifestWriter writes manifest entries as text manifest lines.
+// Additional per-entry fields cannot be represented and are dropped.
+type textManifestWriter struct {
+	w       io.Writer
+	version int
+}
+
+func (w *textManifestWriter) WriteEntry(e manifestEntry) error {
+	line, err := formatManifestEntry(w.version, e.src, e.dest)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w.w, line+"\n")
+	return err
+}
+
+// manifestVersion returns the version of the manifest at path.
+// Empty and non-existent manifests are reported as version 0.
+func manifestVersion(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+
+		return 0, err
+	}
+	defer file.Close()
+
+	m := newManifestReader(file, file.Close, manifestConf{format: FormatText})
+
+	line, err := m.readLine()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return 0, nil
+		}
+
+		return 0, err
+	}
+
+	if line == ManifestHeader {
+		return manifestV2, nil
+	}
+
+	return manifestV1, nil
+}
+
+// readManifest parses the manifest and generates source->dest map.
+//
+// Deprecated: readManifest has been refactored to partially fix [#2], but it still stores the
+// whole mappings into memory.
+// Parsing the manifest should truly be done line-by-line by calling openManifest and
+// readManifestEntry.
+//
+// [#2]: https://github.com/Ghifari160/migrate/issues/2
+func readManifest(log *logger.Logger, manifest string) (map[string]string, error) {
+	m, err := openManifest(log, manifest, manifestConf{format: FormatAuto})
+	if err != nil {
+		return nil, err
+	}
+	defer m.Close()
+
+	mappings := make(map[string]string)
+	eof := false
+
+	for !eof {
+		e, err := readManifestEntry(m)
+		if err != nil {
+			if errors.Is(err, errManifest) {
+				log.Log(logger.LevelWARN, fmt.Sprintf("Error: %s. Skipping.", err))
+			} else if errors.Is(err, io.EOF) {
+				eof = true
+			} else {
+				return nil, err
+			}
+
+			continue
+		}
+
+		mappings[e.src] = e.dest
+	}
+
+	return mappings, nil
+}
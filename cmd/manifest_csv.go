@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// readCSVEntry reads and parses the next record of a CSV manifest.
+//
+// CSV manifests follow RFC 4180 and start with a header row naming their columns.
+// The paths are read from the columns named by the manifest configuration.
+// The optional args, size, and checksum columns hold the additional per-entry fields.
+// Other columns, such as owners or migration waves, are ignored.
+// Blank lines are skipped.
+func readCSVEntry(m *manifestReader) (manifestEntry, error) {
+	if m.csv == nil {
+		m.csv = csv.NewReader(m.r)
+		m.csv.FieldsPerRecord = -1
+
+		header, err := m.csv.Read()
+		if err != nil {
+			return manifestEntry{}, err
+		}
+
+		m.csvColumns = csvColumns(header)
+
+		for _, column := range []string{m.conf.csvSrc, m.conf.csvDest} {
+			if _, found := m.csvColumns[strings.ToLower(column)]; !found {
+				return manifestEntry{}, errors.New("CSV manifest has no " + column + " column")
+			}
+		}
+	}
+
+	record, err := m.csv.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return manifestEntry{}, newManifestErr(parseErr.StartLine, "syntax error: "+parseErr.Err.Error())
+		}
+
+		return manifestEntry{}, err
+	}
+
+	lineN, _ := m.csv.FieldPos(0)
+
+	field := func(column string) (string, bool) {
+		i, found := m.csvColumns[strings.ToLower(column)]
+		if !found || i >= len(record) {
+			return "", false
+		}
+
+		return record[i], true
+	}
+
+	var rec manifestRecord
+	rec.Src, _ = field(m.conf.csvSrc)
+	rec.Dest, _ = field(m.conf.csvDest)
+	rec.Checksum, _ = field("checksum")
+
+	if args, found := field("args"); found && len(args) > 0 {
+		rec.Args = &args
+	}
+
+	if size, found := field("size"); found && len(size) > 0 {
+		n, err := strconv.ParseInt(size, 10, 64)
+		if err != nil {
+			return manifestEntry{}, newManifestErr(lineN, "invalid size "+size)
+		}
+
+		rec.Size = &n
+	}
+
+	return newManifestEntry(m, lineN, rec)
+}
+
+// csvColumns maps the lowercased names of the header columns to their indices.
+func csvColumns(header []string) map[string]int {
+	columns := make(map[string]int, len(header))
+
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, found := columns[name]; !found {
+			columns[name] = i
+		}
+	}
+
+	return columns
+}
+
+// csvHeader returns the header row of the CSV manifest at path.
+// Empty and non-existent manifests have no header.
+func csvHeader(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+	defer file.Close()
+
+	header, err := csv.NewReader(file).Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return header, nil
+}
+
+// csvManifestWriter writes manifest entries as CSV records.
+type csvManifestWriter struct {
+	w       *csv.Writer
+	columns map[string]int
+	width   int
+	src     int
+	dest    int
+}
+
+// newCSVManifestWriter creates a CSV manifest writer.
+// When appending to an existing manifest, records are laid out according to its header row.
+// Otherwise, a header row with the path columns is written first.
+func newCSVManifestWriter(w io.Writer, conf manifestConf, path string, appending bool) (manifestWriter, error) {
+	var header []string
+
+	if appending {
+		var err error
+
+		header, err = csvHeader(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cw := &csvManifestWriter{
+		w: csv.NewWriter(w),
+	}
+
+	if header == nil {
+		header = []string{conf.csvSrc, conf.csvDest}
+
+		err := cw.w.Write(header)
+		if err != nil {
+			return nil, err
+		}
+
+		cw.w.Flush()
+		if err := cw.w.Error(); err != nil {
+			return nil, err
+		}
+	}
+
+	cw.columns = csvColumns(header)
+	cw.width = len(header)
+
+	var found bool
+
+	cw.src, found = cw.columns[strings.ToLower(conf.csvSrc)]
+	if !found {
+		return nil, errors.New("CSV manifest has no " + conf.csvSrc + " column")
+	}
+
+	cw.dest, found = cw.columns[strings.ToLower(conf.csvDest)]
+	if !found {
+		return nil, errors.New("CSV manifest has no " + conf.csvDest + " column")
+	}
+
+	return cw, nil
+}
+
+func (w *csvManifestWriter) WriteEntry(e manifestEntry) error {
+	rec := e.record()
+	record := make([]string, w.width)
+
+	record[w.src] = rec.Src
+	record[w.dest] = rec.Dest
+
+	if i, found := w.columns["args"]; found && rec.Args != nil {
+		record[i] = *rec.Args
+	}
+
+	if i, found := w.columns["size"]; found && rec.Size != nil {
+		record[i] = strconv.FormatInt(*rec.Size, 10)
+	}
+
+	if i, found := w.columns["checksum"]; found {
+		record[i] = rec.Checksum
+	}
+
+	err := w.w.Write(record)
+	if err != nil {
+		return err
+	}
+
+	w.w.Flush()
+	return w.w.Error()
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// readJSONLEntry reads and parses the next line of a JSON Lines manifest.
+// Each line is a JSON object with the fields of manifestRecord.
+// Unknown fields are ignored.
+func readJSONLEntry(m *manifestReader) (manifestEntry, error) {
+	line, lineN, err := m.nextLine()
+	if err != nil {
+		return manifestEntry{}, err
+	}
+
+	if len(strings.TrimSpace(line)) < 1 {
+		return manifestEntry{}, newManifestErr(lineN, "empty line")
+	}
+
+	var rec manifestRecord
+	err = json.Unmarshal([]byte(line), &rec)
+	if err != nil {
+		return manifestEntry{}, newManifestErr(lineN, "syntax error: "+err.Error())
+	}
+
+	return newManifestEntry(m, lineN, rec)
+}
+
+// jsonlManifestWriter writes manifest entries as JSON Lines.
+type jsonlManifestWriter struct {
+	w io.Writer
+}
+
+func (w *jsonlManifestWriter) WriteEntry(e manifestEntry) error {
+	line, err := json.Marshal(e.record())
+	if err != nil {
+		return err
+	}
+
+	_, err = w.w.Write(append(line, '\n'))
+	return err
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// quotingPaths are paths that exercise the quoting of version 2 manifests.
+var quotingPaths = []string{
+	"/plain/path",
+	"/with" + ManifestSep + "separator",
+	`"/leading/quote`,
+	"/inner\"quote",
+	" /leading/space",
+	"/trailing/space ",
+	"/inner space",
+	"/tab\tpath",
+	"/new\nline",
+	"/carriage\rreturn",
+	"/invalid\xffutf8",
+	"/unicode/ünïcødé",
+	"#comment",
+	"@directive",
+	"/${VAR}",
+	"",
+}
+
+func TestSplitQuotedFieldsRoundTrip(t *testing.T) {
+	for _, path := range quotingPaths {
+		line := quoteManifestPath(path) + ManifestSep + quoteManifestPath("/dest")
+
+		fields, err := splitQuotedFields(line)
+		if err != nil {
+			t.Errorf("splitting %q returned error: %v", line, err)
+			continue
+		}
+
+		if len(fields) != 2 || fields[0] != path || fields[1] != "/dest" {
+			t.Errorf("splitting %q = %q, want [%q \"/dest\"]", line, fields, path)
+		}
+	}
+}
+
+func TestSplitQuotedFieldsErrors(t *testing.T) {
+	for _, line := range []string{`"/unterminated;/dest`, `"/a"x;/dest`, `"/bad\q";/dest`} {
+		_, err := splitQuotedFields(line)
+		if err == nil {
+			t.Errorf("splitting %q returned no error", line)
+		}
+	}
+}
+
+// TestFormatManifestEntryRoundTrip checks that entries formatted as version 2 manifest lines are
+// read back as is, including variable references.
+func TestFormatManifestEntryRoundTrip(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test paths are not absolute on Windows")
+	}
+
+	for _, path := range quotingPaths {
+		if !strings.HasPrefix(path, "/") {
+			continue
+		}
+
+		line, err := formatManifestEntry(manifestV2, path, "/dest"+path)
+		if err != nil {
+			t.Errorf("formatting %q returned error: %v", path, err)
+			continue
+		}
+
+		manifest := ManifestHeader + "\n" + line + "\n"
+		m := newManifestReader(strings.NewReader(manifest), nil, manifestConf{format: FormatText})
+
+		e, err := readManifestEntry(m)
+		if err != nil {
+			t.Errorf("reading %q returned error: %v", line, err)
+			continue
+		}
+
+		if e.src != path || e.dest != "/dest"+path {
+			t.Errorf("reading %q = %q => %q, want %q => %q", line, e.src, e.dest, path, "/dest"+path)
+		}
+	}
+}
+
+func TestFormatManifestEntryV1(t *testing.T) {
+	line, err := formatManifestEntry(manifestV1, "/a b", "/c")
+	if err != nil || line != "/a b"+ManifestSep+"/c" {
+		t.Errorf("formatting version 1 entry = %q, %v", line, err)
+	}
+
+	for _, path := range []string{"/with" + ManifestSep + "separator", "/new\nline", "#comment", " @directive"} {
+		_, err := formatManifestEntry(manifestV1, path, "/dest")
+		if err == nil {
+			t.Errorf("formatting %q as version 1 returned no error", path)
+		}
+	}
+}
+
+// TestManifestFromArgsLine checks that the entry given as arguments is reported as line 1.
+func TestManifestFromArgsLine(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test paths are not absolute on Windows")
+	}
+
+	m, err := manifestFromArgs(nil, "/a"+ManifestSep+"b", "/c d", manifestConf{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := readManifestEntry(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.line != 1 {
+		t.Errorf("entry is at line %d, want 1", e.line)
+	}
+
+	if e.src != "/a"+ManifestSep+"b" || e.dest != "/c d" {
+		t.Errorf("entry = %q => %q", e.src, e.dest)
+	}
+}
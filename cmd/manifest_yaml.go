@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// readYAMLEntry reads and parses the next item of a YAML manifest.
+//
+// YAML manifests are read line by line, so only a subset of YAML is supported: the document is a
+// block sequence starting at the first column, and each item is a block mapping of scalars.
+//
+//   - src: /data/projects/
+//     dest: "/mnt/nas1/projects/"
+//     args: -avz
+//     size: 1048576
+//
+// Scalars may be plain, single-quoted, or double-quoted with Go string literal escapes.
+// Comments, blank lines, and document markers are ignored, as are unknown keys.
+func readYAMLEntry(m *manifestReader) (manifestEntry, error) {
+	fields := make(map[string]string)
+	start := 0
+	var itemErr error
+
+	for {
+		line, lineN, err := m.nextLine()
+		if err != nil {
+			if errors.Is(err, io.EOF) && start > 0 {
+				break
+			}
+
+			return manifestEntry{}, err
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if len(trimmed) < 1 || strings.HasPrefix(trimmed, "#") || trimmed == "---" || trimmed == "..." {
+			continue
+		}
+
+		if line == "-" || strings.HasPrefix(line, "- ") {
+			if start > 0 {
+				m.unreadLine(line, lineN)
+				break
+			}
+
+			start = lineN
+			trimmed = strings.TrimSpace(line[1:])
+			if len(trimmed) < 1 {
+				continue
+			}
+		} else if start < 1 {
+			return manifestEntry{}, newManifestErr(lineN, "syntax error: expected sequence item")
+		} else if line[0] != ' ' && line[0] != '\t' {
+			if itemErr == nil {
+				itemErr = newManifestErr(lineN, "syntax error: expected indented mapping")
+			}
+			continue
+		}
+
+		key, value, err := parseYAMLPair(trimmed)
+		if err != nil {
+			if itemErr == nil {
+				itemErr = newManifestErr(lineN, "syntax error: "+err.Error())
+			}
+			continue
+		}
+
+		fields[key] = value
+	}
+
+	if itemErr != nil {
+		return manifestEntry{}, itemErr
+	}
+
+	rec := manifestRecord{
+		Src:      fields["src"],
+		Dest:     fields["dest"],
+		Checksum: fields["checksum"],
+	}
+
+	if args, found := fields["args"]; found {
+		rec.Args = &args
+	}
+
+	if size, found := fields["size"]; found {
+		n, err := strconv.ParseInt(size, 10, 64)
+		if err != nil {
+			return manifestEntry{}, newManifestErr(start, "invalid size "+size)
+		}
+
+		rec.Size = &n
+	}
+
+	return newManifestEntry(m, start, rec)
+}
+
+// parseYAMLPair parses a single key: value pair of a block mapping.
+func parseYAMLPair(pair string) (string, string, error) {
+	i := strings.Index(pair, ":")
+	if i < 1 || (i+1 < len(pair) && pair[i+1] != ' ' && pair[i+1] != '\t') {
+		return "", "", errors.New("expected key: value")
+	}
+
+	key := strings.TrimSpace(pair[:i])
+	value, err := parseYAMLScalar(strings.TrimSpace(pair[i+1:]))
+	if err != nil {
+		return "", "", err
+	}
+
+	return key, value, nil
+}
+
+// parseYAMLScalar parses a flow scalar.
+func parseYAMLScalar(scalar string) (string, error) {
+	switch {
+	case strings.HasPrefix(scalar, `"`):
+		quoted, err := strconv.QuotedPrefix(scalar)
+		if err != nil {
+			return "", errors.New("unterminated double-quoted scalar")
+		}
+
+		if !isYAMLTrailer(scalar[len(quoted):]) {
+			return "", errors.New("unexpected text after double-quoted scalar")
+		}
+
+		return strconv.Unquote(quoted)
+
+	case strings.HasPrefix(scalar, "'"):
+		var value strings.Builder
+
+		for i := 1; i < len(scalar); i++ {
+			if scalar[i] != '\'' {
+				value.WriteByte(scalar[i])
+				continue
+			}
+
+			if i+1 < len(scalar) && scalar[i+1] == '\'' {
+				value.WriteByte('\'')
+				i++
+				continue
+			}
+
+			if !isYAMLTrailer(scalar[i+1:]) {
+				return "", errors.New("unexpected text after single-quoted scalar")
+			}
+
+			return value.String(), nil
+		}
+
+		return "", errors.New("unterminated single-quoted scalar")
+
+	default:
+		if i := strings.Index(scalar, " #"); i >= 0 {
+			scalar = scalar[:i]
+		}
+
+		return strings.TrimSpace(scalar), nil
+	}
+}
+
+// isYAMLTrailer checks if the text following a quoted scalar is empty or a comment.
+func isYAMLTrailer(s string) bool {
+	s = strings.TrimSpace(s)
+	return len(s) < 1 || strings.HasPrefix(s, "#")
+}
+
+// yamlManifestWriter writes manifest entries as a YAML block sequence.
+// Strings are always double-quoted, so that any path can be represented.
+type yamlManifestWriter struct {
+	w io.Writer
+}
+
+func (w *yamlManifestWriter) WriteEntry(e manifestEntry) error {
+	rec := e.record()
+
+	var item strings.Builder
+
+	item.WriteString("- src: " + strconv.Quote(rec.Src) + "\n")
+	item.WriteString("  dest: " + strconv.Quote(rec.Dest) + "\n")
+
+	if rec.Args != nil {
+		item.WriteString("  args: " + strconv.Quote(*rec.Args) + "\n")
+	}
+
+	if rec.Size != nil {
+		item.WriteString("  size: " + strconv.FormatInt(*rec.Size, 10) + "\n")
+	}
+
+	if len(rec.Checksum) > 0 {
+		item.WriteString("  checksum: " + strconv.Quote(rec.Checksum) + "\n")
+	}
+
+	_, err := io.WriteString(w.w, item.String())
+	return err
+}
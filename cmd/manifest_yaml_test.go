@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseYAMLScalar(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "", want: ""},
+		{in: "/plain/path", want: "/plain/path"},
+		{in: "/plain # comment", want: "/plain"},
+		{in: "/not#comment", want: "/not#comment"},
+		{in: `"/double\tquoted"`, want: "/double\tquoted"},
+		{in: `"/a b" # comment`, want: "/a b"},
+		{in: `'/single ''quoted'''`, want: "/single 'quoted'"},
+		{in: `'/no\escape'`, want: `/no\escape`},
+		{in: `'/a b'   # comment`, want: "/a b"},
+		{in: `"/unterminated`, wantErr: true},
+		{in: `'/unterminated`, wantErr: true},
+		{in: `"/a" b`, wantErr: true},
+		{in: `'/a' b`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := parseYAMLScalar(test.in)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("parseYAMLScalar(%q) = %q, want error", test.in, got)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("parseYAMLScalar(%q) returned error: %v", test.in, err)
+			continue
+		}
+
+		if got != test.want {
+			t.Errorf("parseYAMLScalar(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+// readYAMLManifest reads every entry of the YAML manifest, returning the entries and the errors of
+// invalid items.
+func readYAMLManifest(t *testing.T, manifest string) ([]manifestEntry, []error) {
+	t.Helper()
+
+	m := newManifestReader(strings.NewReader(manifest), nil, manifestConf{format: FormatYAML})
+	entries := make([]manifestEntry, 0)
+	errs := make([]error, 0)
+
+	for {
+		e, err := readManifestEntry(m)
+		if errors.Is(err, io.EOF) {
+			return entries, errs
+		}
+
+		if err != nil {
+			if !errors.Is(err, errManifest) {
+				t.Fatal(err)
+			}
+
+			errs = append(errs, err)
+			continue
+		}
+
+		entries = append(entries, e)
+	}
+}
+
+func TestReadYAMLEntry(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test paths are not absolute on Windows")
+	}
+
+	manifest := `---
+# Projects
+- src: /data/projects/
+  dest: "/mnt/nas1/projects/"
+  args: -avz
+  size: 1048576
+  unknown: ignored
+
+-
+  src: '/data/it''s'
+  dest: /mnt/nas1/its # comment
+  checksum: sha256:abcd
+...
+`
+
+	entries, errs := readYAMLManifest(t, manifest)
+	if len(errs) > 0 {
+		t.Fatalf("reading manifest returned errors: %v", errs)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("read %d entries, want 2", len(entries))
+	}
+
+	e := entries[0]
+	if e.line != 3 || e.src != "/data/projects/" || e.dest != "/mnt/nas1/projects/" {
+		t.Errorf("first entry = line %d: %q => %q", e.line, e.src, e.dest)
+	}
+
+	if !reflect.DeepEqual(e.argv, []string{"-avz"}) || e.size == nil || *e.size != 1048576 {
+		t.Errorf("first entry has argv %q and size %v", e.argv, e.size)
+	}
+
+	e = entries[1]
+	if e.line != 9 || e.src != "/data/it's" || e.dest != "/mnt/nas1/its" || e.checksum != "sha256:abcd" {
+		t.Errorf("second entry = line %d: %q => %q (%s)", e.line, e.src, e.dest, e.checksum)
+	}
+}
+
+// TestReadYAMLEntryErrors checks that invalid items are reported at their line and do not prevent
+// reading the items that follow.
+func TestReadYAMLEntryErrors(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test paths are not absolute on Windows")
+	}
+
+	manifest := `- src: /a
+  dest: "/unterminated
+- src: /b
+dest: /unindented
+- src: /c
+  size: big
+  dest: /d
+- src: /e
+  dest: /f
+`
+
+	entries, errs := readYAMLManifest(t, manifest)
+
+	want := []string{
+		"syntax error: unterminated double-quoted scalar at line 2",
+		"syntax error: expected indented mapping at line 4",
+		"invalid size big at line 5",
+	}
+
+	if len(errs) != len(want) {
+		t.Fatalf("reading manifest returned errors %v, want %q", errs, want)
+	}
+
+	for i, err := range errs {
+		if err.Error() != want[i] {
+			t.Errorf("error %d = %q, want %q", i, err.Error(), want[i])
+		}
+	}
+
+	if len(entries) != 1 || entries[0].src != "/e" || entries[0].dest != "/f" {
+		t.Errorf("read entries %v, want /e => /f", entries)
+	}
+}
+
+func TestYAMLManifestWriterRoundTrip(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test paths are not absolute on Windows")
+	}
+
+	size := int64(42)
+	written := []manifestEntry{
+		{src: "/a b/\"quoted\"", dest: "/new\nline", argv: []string{"-a", "b c"}, size: &size},
+		{src: "/${VAR}/'single'", dest: "/#hash", checksum: "sha256:abcd"},
+	}
+
+	var buffer bytes.Buffer
+	w := &yamlManifestWriter{w: &buffer}
+
+	for _, e := range written {
+		err := w.WriteEntry(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, errs := readYAMLManifest(t, buffer.String())
+	if len(errs) > 0 {
+		t.Fatalf("reading %q returned errors: %v", buffer.String(), errs)
+	}
+
+	if len(entries) != len(written) {
+		t.Fatalf("read %d entries, want %d", len(entries), len(written))
+	}
+
+	for i, e := range entries {
+		want := written[i]
+
+		if e.src != want.src || e.dest != want.dest || e.checksum != want.checksum ||
+			!reflect.DeepEqual(e.argv, want.argv) || !reflect.DeepEqual(e.size, want.size) {
+			t.Errorf("entry %d = %+v, want %+v", i, e, want)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+)
+
+// Names of the dry run plan files written to the logging directory.
+const (
+	PlanJSONName = "plan.json"
+	PlanCSVName  = "plan.csv"
+)
+
+// Planned actions on the copy of a manifest entry.
+const (
+	actionCreate    = "create"
+	actionMerge     = "merge"
+	actionOverwrite = "overwrite"
+	actionNone      = "none"
+)
+
+// planColumns is the header of the plan in CSV format.
+var planColumns = []string{
+	"line", "file", "src", "dest", "target", "src_exists", "files", "bytes", "dest_exists", "action",
+	"command", "error",
+}
+
+// planEntry is what a dry run would do with a manifest entry.
+type planEntry struct {
+	Line       int      `json:"line"`
+	File       string   `json:"file,omitempty"`
+	Src        string   `json:"src"`
+	Dest       string   `json:"dest"`
+	Target     string   `json:"target"`
+	SrcExists  bool     `json:"src_exists"`
+	Files      int64    `json:"files"`
+	Bytes      int64    `json:"bytes"`
+	DestExists bool     `json:"dest_exists"`
+	Action     string   `json:"action"`
+	Argv       []string `json:"argv"`
+	Error      string   `json:"error,omitempty"`
+
+	// seq is the position of the entry in reading order.
+	seq int
+}
+
+// runPlan collects the plan of a dry run.
+// It is concurrency-safe through the use of [sync.Mutex].
+type runPlan struct {
+	m       sync.Mutex
+	entries []planEntry
+}
+
+// planManifestEntry examines the manifest entry and returns what copying it with argv would do.
+// The source is sized following trailing slash semantics, and the copy is located with
+// copyTarget, as when copying.
+// The copy of a missing source is located with entryTarget instead.
+func planManifestEntry(e manifestEntry, argv []string) planEntry {
+	p := planEntry{
+		Line:   e.line,
+		File:   e.file,
+		Src:    e.src,
+		Dest:   e.dest,
+		Argv:   argv,
+		Action: actionNone,
+		seq:    e.seq,
+	}
+
+	s, err := os.Stat(e.src)
+	if err != nil {
+		p.Target = entryTarget(e.src, e.dest)
+		p.Error = err.Error()
+		return p
+	}
+	p.SrcExists = true
+
+	p.Target, err = copyTarget(e.src, e.dest)
+	if err != nil {
+		p.Target = entryTarget(e.src, e.dest)
+		p.Error = err.Error()
+		return p
+	}
+
+	p.Bytes, p.Files, err = pathUsage(e.src, hasTrailingSlash(e.src))
+	if err != nil {
+		p.Error = err.Error()
+	}
+
+	d, err := os.Stat(p.Target)
+	switch {
+	case err == nil:
+		p.DestExists = true
+		p.Action = actionOverwrite
+		if s.IsDir() && d.IsDir() {
+			p.Action = actionMerge
+		}
+
+	case os.IsNotExist(err):
+		p.Action = actionCreate
+
+	default:
+		p.Error = err.Error()
+	}
+
+	return p
+}
+
+// add adds the planned entry.
+func (p *runPlan) add(e planEntry) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	p.entries = append(p.entries, e)
+}
+
+// sorted returns the planned entries in manifest order, regardless of their completion order.
+func (p *runPlan) sorted() []planEntry {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	sort.Slice(p.entries, func(i, j int) bool {
+		return p.entries[i].seq < p.entries[j].seq
+	})
+
+	return append([]planEntry(nil), p.entries...)
+}
+
+// WriteTable writes the plan as a human-readable table.
+func (p *runPlan) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "LOCATION\tSOURCE\tTARGET\tSRC\tDEST\tFILES\tSIZE\tACTION\tCOMMAND")
+
+	for _, e := range p.sorted() {
+		location := manifestEntry{line: e.Line, file: e.File}.location()
+
+		files, size := "-", "-"
+		if e.SrcExists {
+			files = strconv.FormatInt(e.Files, 10)
+			size = formatBytes(uint64(e.Bytes))
+		}
+
+		action := e.Action
+		if len(e.Error) > 0 {
+			action += " (" + e.Error + ")"
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", location, e.Src, e.Target,
+			existence(e.SrcExists), existence(e.DestExists), files, size, action, joinArgs(e.Argv))
+	}
+
+	return tw.Flush()
+}
+
+// existence describes whether a path exists.
+func existence(exists bool) string {
+	if exists {
+		return "exists"
+	}
+
+	return "missing"
+}
+
+// WriteJSON writes the plan as a JSON array.
+func (p *runPlan) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(p.sorted())
+}
+
+// WriteCSV writes the plan as CSV with a header row.
+func (p *runPlan) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+
+	err := cw.Write(planColumns)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range p.sorted() {
+		err = cw.Write([]string{
+			strconv.Itoa(e.Line),
+			e.File,
+			e.Src,
+			e.Dest,
+			e.Target,
+			strconv.FormatBool(e.SrcExists),
+			strconv.FormatInt(e.Files, 10),
+			strconv.FormatInt(e.Bytes, 10),
+			strconv.FormatBool(e.DestExists),
+			e.Action,
+			joinArgs(e.Argv),
+			e.Error,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// writePlanFiles writes the plan as JSON and CSV into dir, returning the paths written.
+func writePlanFiles(p *runPlan, dir string) ([]string, error) {
+	files := []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{PlanJSONName, p.WriteJSON},
+		{PlanCSVName, p.WriteCSV},
+	}
+
+	paths := make([]string, 0, len(files))
+
+	for _, f := range files {
+		path := filepath.Join(dir, f.name)
+
+		file, err := os.Create(path)
+		if err != nil {
+			return paths, err
+		}
+
+		err = f.write(file)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return paths, err
+		}
+
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestPlanManifestEntryTarget checks that the plan locates copies as copying does.
+func TestPlanManifestEntryTarget(t *testing.T) {
+	dir := t.TempDir()
+
+	src := filepath.Join(dir, "f.txt")
+	err := os.WriteFile(src, []byte("f"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		src  string
+		dest string
+		want string
+	}{
+		{name: "file to new name", src: src, dest: filepath.Join(dir, "new", "name.txt"),
+			want: filepath.Join(dir, "new", "name.txt")},
+		{name: "file into dir", src: src, dest: filepath.Join(dir, "new") + PathSep,
+			want: filepath.Join(dir, "new", "f.txt")},
+		{name: "missing source", src: filepath.Join(dir, "nope"), dest: filepath.Join(dir, "d"),
+			want: filepath.Join(dir, "d", "nope")},
+	}
+
+	for _, test := range tests {
+		p := planManifestEntry(manifestEntry{src: test.src, dest: test.dest}, nil)
+		if p.Target != test.want {
+			t.Errorf("%s: target = %s, want %s", test.name, p.Target, test.want)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghifari160/migrate/internal/exit"
+	"github.com/ghifari160/migrate/internal/logger"
+)
+
+// diskSpace is the free space of a file system.
+type diskSpace struct {
+	// id identifies the file system.
+	id string
+	// free is the number of bytes available to the current user.
+	free uint64
+	// freeFiles is the number of free inodes, known only if hasFiles is true.
+	freeFiles uint64
+	hasFiles  bool
+}
+
+// capacity tallies the space needed on a destination file system.
+type capacity struct {
+	// path is the first destination path found on the file system.
+	path  string
+	space diskSpace
+	bytes uint64
+	files uint64
+}
+
+// sufficient checks if the file system has room for the space needed.
+func (c *capacity) sufficient() bool {
+	return c.bytes <= c.space.free && (!c.space.hasFiles || c.files <= c.space.freeFiles)
+}
+
+func (c *capacity) String() string {
+	files := "unknown"
+	if c.space.hasFiles {
+		files = fmt.Sprintf("%d", c.space.freeFiles)
+	}
+
+	verdict := "OK"
+	if !c.sufficient() {
+		verdict = "INSUFFICIENT"
+	}
+
+	return fmt.Sprintf("%s: %s needed, %s free; %d files needed, %s free. %s",
+		c.path, formatBytes(c.bytes), formatBytes(c.space.free), c.files, files, verdict)
+}
+
+// capacityCheck sums the space needed by the sources of manifest entries per destination file
+// system, in the order the file systems are found.
+type capacityCheck struct {
+	log        *logger.Logger
+	entries    int
+	capacities []*capacity
+	byID       map[string]*capacity
+	byDir      map[string]*capacity
+}
+
+func newCapacityCheck(log *logger.Logger) *capacityCheck {
+	return &capacityCheck{
+		log:        log,
+		capacities: make([]*capacity, 0),
+		byID:       make(map[string]*capacity),
+		byDir:      make(map[string]*capacity),
+	}
+}
+
+// preflight reads the manifest in a pass of its own and checks that the destinations of its
+// entries have room for their sources, leaving c.m unread for copying.
+// Entries skipped when resuming are not counted, and invalid entries are left to the copying pass.
+func (c *CmdMigrate) preflight() int {
+	m, status := openManifestArgs(c.log, c.f.Args(), c.c.manifest)
+	if status != exit.RDY {
+		return status
+	}
+	defer m.Close()
+
+	check := newCapacityCheck(c.log)
+
+	for {
+		e, err := readManifestEntry(m)
+		if err != nil {
+			if errors.Is(err, errManifest) {
+				continue
+			} else if errors.Is(err, io.EOF) {
+				break
+			}
+
+			c.log.Log(logger.LevelError, "Error reading manifest: "+err.Error())
+			return exit.ManifestRead
+		}
+
+		if c.c.resume && c.journal.Succeeded(e.line, e.src, e.dest) {
+			continue
+		}
+
+		check.add(e)
+	}
+
+	c.log.Log(logger.LevelINFO, fmt.Sprintf("Checked capacity for %d entries.", check.entries))
+
+	var report strings.Builder
+	report.WriteString("Pre-flight capacity check:\n")
+
+	sufficient := true
+	for _, capacity := range check.capacities {
+		report.WriteString("  " + capacity.String() + "\n")
+		sufficient = sufficient && capacity.sufficient()
+	}
+
+	fmt.Print(report.String())
+	c.log.WriteString(report.String())
+
+	if !sufficient {
+		c.log.Log(logger.LevelError, "Insufficient capacity. Aborting.")
+		return exit.InsufficientCapacity
+	}
+
+	return exit.Norm
+}
+
+// add adds the space needed by the source of the manifest entry to its destination file system.
+// Sources are sized following trailing slash semantics: a source with a trailing slash needs no
+// room for itself, only for its contents.
+// Entries that cannot be sized are logged and not counted.
+func (c *capacityCheck) add(e manifestEntry) {
+	c.entries++
+
+	bytes, files, err := pathUsage(e.src, hasTrailingSlash(e.src))
+	if err != nil {
+		c.log.Log(logger.LevelWARN, "Error sizing "+e.src+" at "+e.location()+": "+err.Error())
+		return
+	}
+
+	dir, err := existingAncestor(filepath.Dir(entryTarget(e.src, e.dest)))
+	if err != nil {
+		c.log.Log(logger.LevelWARN, "Error locating destination of "+e.src+" at "+e.location()+": "+err.Error())
+		return
+	}
+
+	total, found := c.byDir[dir]
+	if !found {
+		space, err := statDisk(dir)
+		if err != nil {
+			c.log.Log(logger.LevelWARN, "Error checking free space of "+dir+": "+err.Error())
+			return
+		}
+
+		total, found = c.byID[space.id]
+		if !found {
+			total = &capacity{path: dir, space: space}
+			c.byID[space.id] = total
+			c.capacities = append(c.capacities, total)
+		}
+		c.byDir[dir] = total
+	}
+
+	total.bytes += uint64(bytes)
+	total.files += uint64(files)
+}
+
+// existingAncestor returns path or its nearest ancestor that exists.
+func existingAncestor(path string) (string, error) {
+	dir := filepath.Clean(path)
+
+	for {
+		_, err := os.Stat(dir)
+		if err == nil {
+			return dir, nil
+		}
+
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", err
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ghifari160/migrate/internal/logger"
+)
+
+// TestCapacityCheckAdd checks that the sources of entries sharing a file system are tallied
+// together, following trailing slash semantics.
+func TestCapacityCheckAdd(t *testing.T) {
+	dir := t.TempDir()
+
+	src := filepath.Join(dir, "s")
+
+	err := os.MkdirAll(src, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(filepath.Join(src, "f"), []byte("data"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log, err := logger.OpenLogs(filepath.Join(dir, "logs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+
+	check := newCapacityCheck(log)
+	check.add(manifestEntry{src: src + PathSep, dest: filepath.Join(dir, "a")})
+	check.add(manifestEntry{src: filepath.Join(src, "f"), dest: filepath.Join(dir, "b", "c") + PathSep})
+	check.add(manifestEntry{src: filepath.Join(dir, "missing"), dest: filepath.Join(dir, "d")})
+
+	if check.entries != 3 {
+		t.Errorf("checked %d entries, want 3", check.entries)
+	}
+
+	if len(check.capacities) != 1 {
+		t.Fatalf("found %d file systems, want 1", len(check.capacities))
+	}
+
+	capacity := check.capacities[0]
+	if capacity.bytes != 8 || capacity.files != 2 {
+		t.Errorf("tallied %d bytes and %d files, want 8 bytes and 2 files", capacity.bytes, capacity.files)
+	}
+}
@@ -0,0 +1,249 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ghifari160/migrate/internal/logger"
+)
+
+// rewriteRule rewrites paths matching a regular expression.
+// Rules are written as s<d>REGEXP<d>REPLACEMENT<d>[FLAGS], where <d> is any delimiter character.
+// The delimiter is escaped with a backslash.
+// \0 through \9 in the replacement refer to the submatches of the regular expression.
+// Flag g replaces every match instead of the first one, and flag i matches case-insensitively.
+type rewriteRule struct {
+	re     *regexp.Regexp
+	repl   string
+	global bool
+}
+
+// parseRewriteRule parses the rewrite rule.
+func parseRewriteRule(rule string) (rewriteRule, error) {
+	if len(rule) < 2 || rule[0] != 's' {
+		return rewriteRule{}, errors.New("rule must be formatted as s#REGEXP#REPLACEMENT#")
+	}
+
+	delim := rule[1]
+	if delim == '\\' || delim == '\n' {
+		return rewriteRule{}, errors.New("invalid delimiter")
+	}
+
+	parts := make([]string, 0, 3)
+	var part strings.Builder
+
+	for i := 2; i < len(rule); i++ {
+		switch {
+		case rule[i] == '\\' && i+1 < len(rule) && rule[i+1] == delim:
+			part.WriteByte(delim)
+			i++
+
+		case rule[i] == delim && len(parts) < 2:
+			parts = append(parts, part.String())
+			part.Reset()
+
+		default:
+			part.WriteByte(rule[i])
+		}
+	}
+
+	if len(parts) < 2 {
+		return rewriteRule{}, errors.New("rule must be formatted as s#REGEXP#REPLACEMENT#")
+	}
+
+	pattern, repl, flags := parts[0], parts[1], part.String()
+
+	r := rewriteRule{}
+	for _, flag := range flags {
+		switch flag {
+		case 'g':
+			r.global = true
+
+		case 'i':
+			pattern = "(?i)" + pattern
+
+		default:
+			return rewriteRule{}, fmt.Errorf("unknown flag %c", flag)
+		}
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return rewriteRule{}, err
+	}
+
+	r.re = re
+	r.repl = rewriteTemplate(repl)
+
+	return r, nil
+}
+
+// rewriteTemplate converts the replacement of a rewrite rule into a template for
+// [regexp.Regexp.Expand].
+func rewriteTemplate(repl string) string {
+	var template strings.Builder
+
+	for i := 0; i < len(repl); i++ {
+		switch {
+		case repl[i] == '$':
+			template.WriteString("$$")
+
+		case repl[i] == '\\' && i+1 < len(repl) && repl[i+1] >= '0' && repl[i+1] <= '9':
+			template.WriteString("${" + string(repl[i+1]) + "}")
+			i++
+
+		case repl[i] == '\\' && i+1 < len(repl) && repl[i+1] == '\\':
+			template.WriteByte('\\')
+			i++
+
+		default:
+			template.WriteByte(repl[i])
+		}
+	}
+
+	return template.String()
+}
+
+// apply rewrites path and reports whether the rule matched.
+func (r rewriteRule) apply(path string) (string, bool) {
+	if !r.re.MatchString(path) {
+		return path, false
+	}
+
+	if r.global {
+		return r.re.ReplaceAllString(path, r.repl), true
+	}
+
+	loc := r.re.FindStringSubmatchIndex(path)
+	rewritten := r.re.ExpandString(nil, r.repl, path, loc)
+
+	return path[:loc[0]] + string(rewritten) + path[loc[1]:], true
+}
+
+// rewriteRules holds rewrite rules in the order they are applied.
+// It implements flag.Value, adding a rule each time the flag is set.
+type rewriteRules struct {
+	rules []rewriteRule
+	raw   []string
+}
+
+func (r *rewriteRules) String() string {
+	if r == nil {
+		return ""
+	}
+
+	return strings.Join(r.raw, " ")
+}
+
+func (r *rewriteRules) Set(rule string) error {
+	parsed, err := parseRewriteRule(rule)
+	if err != nil {
+		return err
+	}
+
+	r.rules = append(r.rules, parsed)
+	r.raw = append(r.raw, rule)
+
+	return nil
+}
+
+// rewriteFile adds the rewrite rules read from a file, one per line, to rules.
+// Blank lines and lines starting with # are ignored.
+// It implements flag.Value.
+type rewriteFile struct {
+	rules *rewriteRules
+}
+
+func (f rewriteFile) String() string {
+	return ""
+}
+
+func (f rewriteFile) Set(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineN := 0
+
+	for scanner.Scan() {
+		lineN++
+
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) < 1 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		err = f.rules.Set(line)
+		if err != nil {
+			return fmt.Errorf("%s at line %d of %s", err, lineN, path)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// rewrite applies every rule in order to path.
+// It reports whether any rule matched.
+func (r *rewriteRules) rewrite(path string) (string, bool) {
+	rewritten := false
+
+	for _, rule := range r.rules {
+		var matched bool
+		path, matched = rule.apply(path)
+		rewritten = rewritten || matched
+	}
+
+	return path, rewritten
+}
+
+// rewriteEntry rewrites the manifest entry with the rewrite rules.
+// The rules are applied to the source path, and the rewritten path becomes the path of the copy:
+// a source directory has its contents copied into the rewritten path, while a source file is
+// copied to the rewritten path.
+// Entries whose source matches no rule are returned as is.
+func rewriteEntry(rules *rewriteRules, e manifestEntry) manifestEntry {
+	if rules == nil || len(rules.rules) < 1 {
+		return e
+	}
+
+	src := filepath.Clean(e.src)
+
+	dest, rewritten := rules.rewrite(src)
+	if !rewritten {
+		return e
+	}
+
+	if isDir(src) {
+		src += string(filepath.Separator)
+	}
+
+	if !filepath.IsAbs(dest) {
+		if abs, err := filepath.Abs(dest); err == nil {
+			dest = abs
+		}
+	}
+
+	e.src = src
+	e.dest = filepath.Clean(dest)
+	e.rewritten = true
+
+	return e
+}
+
+// logRewrite logs the rewritten mapping of the manifest entry, if any.
+func logRewrite(log *logger.Logger, e manifestEntry) {
+	if !e.rewritten {
+		return
+	}
+
+	log.LogWith(logger.LevelINFO, fmt.Sprintf("Rewrote %s at %s to %s.", e.src, e.location(), e.dest),
+		logger.Fields{Line: e.line, Src: e.src, Dest: e.dest})
+}
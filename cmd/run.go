@@ -1,70 +1,85 @@
 package cmd
 
 import (
-	"bufio"
-	"bytes"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/ghifari160/migrate/internal/exit"
+	"github.com/ghifari160/migrate/internal/journal"
 	"github.com/ghifari160/migrate/internal/logger"
 )
 
+const JournalName = "journal.txt"
+
 type CmdMigrate struct {
 	f          *flag.FlagSet
 	printFlags bool
-	m          *bufio.Reader
-	closeM     func() error
+	m          *manifestReader
 	c          migrateConf
 	log        *logger.Logger
+	journal    *journal.Journal
 }
 
 type migrateConf struct {
-	dryRun bool
-	util   string
-	args   string
+	log      logConf
+	manifest manifestConf
+	dryRun   bool
+	util     string
+	args     string
+	argv     []string
+	backend  Backend
+	jobs     int
+	resume   bool
+	journal  string
+
+	retryPartial int
+	preflight    bool
+
+	// plan collects the plan of a dry run.
+	plan *runPlan
 }
 
 func NewCmdMigrate() Cmd {
-	var util, args string
-
-	util = "rsync"
-	args = "-avr"
+	util := "rsync"
 
 	if runtime.GOOS == "windows" {
 		util = "robocopy"
-		args = "/E /COPY:DAT"
 	}
 
 	return &CmdMigrate{
 		f: NewFlagSet("run"),
 		c: migrateConf{
 			util: util,
-			args: args,
+			jobs: 1,
 		},
 	}
 }
 
 func (c *CmdMigrate) Command(args []string) int {
 	var err error
-	var manifest, src, dest string
-
-	c.log, err = logger.OpenLogs("logs")
-	if err != nil {
-		return exit.LogError
-	}
-	fmt.Println("Logging to " + c.log.DirAbs() + ".")
 
 	c.f.BoolVar(&c.c.dryRun, "dryrun", c.c.dryRun, "Run in dry run mode.")
-	c.f.StringVar(&c.c.util, "util", c.c.util, "Copying utility.")
-	c.f.StringVar(&c.c.args, "util-args", c.c.args, "Copying utility arguments.")
+	c.f.StringVar(&c.c.util, "util", c.c.util, "Copying utility. Use \""+UtilBuiltin+"\" for the native copy engine.")
+	c.f.StringVar(&c.c.args, "util-args", c.c.args, "Copying utility arguments. "+
+		"Defaults to the arguments of the copying utility (e.g. -avr for rsync). "+
+		"Placeholders "+PlaceholderSrc+" and "+PlaceholderDest+" are replaced with the paths, "+
+		"which are otherwise appended to the arguments.")
+	c.f.IntVar(&c.c.jobs, "jobs", c.c.jobs, "Number of manifest entries to copy concurrently.")
+	c.f.BoolVar(&c.c.resume, "resume", c.c.resume, "Skip manifest entries recorded as succeeded in the journal.")
+	c.f.IntVar(&c.c.retryPartial, "retry-partial", c.c.retryPartial, "Number of times to retry partially copied entries.")
+	c.f.BoolVar(&c.c.preflight, "preflight", c.c.preflight, "Check that the destinations have room for the sources "+
+		"before copying, aborting if they do not.")
+	c.f.StringVar(&c.c.journal, "journal", c.c.journal, "Journal path. Defaults to "+JournalName+" in the logging directory, shared by every run.")
+	manifestFlags(c.f, &c.c.manifest)
+	logFlags(c.f, &c.c.log)
 
 	err = c.f.Parse(args)
 	if err != nil {
@@ -72,238 +87,269 @@ func (c *CmdMigrate) Command(args []string) int {
 		return exit.Usage
 	}
 
-	args = c.f.Args()
+	if c.c.jobs < 1 || c.c.retryPartial < 0 {
+		c.printFlags = true
+		return exit.Usage
+	}
 
-	if len(args) < 1 {
-		manifest = ManifestName
-	} else if len(args) < 2 {
-		if len(args[0]) < 1 {
-			return exit.Usage
-		}
+	if !c.c.log.valid() {
+		c.printFlags = true
+		return exit.Usage
+	}
 
-		manifest = args[0]
-	} else {
-		if len(args[0]) < 1 || len(args[1]) < 1 {
-			return exit.Usage
-		}
+	c.log, err = openLogs(c.c.log)
+	if err != nil {
+		return exit.LogError
+	}
+	fmt.Println("Logging to " + c.log.DirAbs() + ".")
 
-		src = args[0]
-		dest = args[1]
+	c.c.backend = newBackend(c.c.util)
+	if !isFlagSet(c.f, "util-args") {
+		c.c.args = c.c.backend.DefaultArgs()
+	}
+
+	c.c.argv, err = splitArgs(c.c.args)
+	if err != nil {
+		c.log.Log(logger.LevelError, "Error parsing utility arguments: "+err.Error())
+		c.printFlags = true
+		return exit.Usage
 	}
 
-	util, err := exec.LookPath(c.c.util)
-	if err != nil || len(util) < 1 {
+	err = c.c.backend.Prepare(c.c.util, c.c.argv)
+	if err != nil {
+		c.log.Log(logger.LevelError, "Error preparing "+c.c.backend.Name()+": "+err.Error())
 		return exit.UtilNotFound
 	}
-	c.c.util = util
-
-	if len(manifest) > 0 {
-		c.m, c.closeM, err = openManifest(c.log, manifest)
-	} else {
-		src, dest, norm := normPaths(src, dest)
-		if !norm {
-			c.log.Log(logger.LevelWARN, "Cannot normalize paths for "+src+" => "+dest+".")
-			return exit.ManifestRead
-		}
 
-		c.m, c.closeM, err = manifestFromArgs(c.log, src, dest)
+	var status int
+	c.m, status = openManifestArgs(c.log, c.f.Args(), c.c.manifest)
+	if status != exit.RDY {
+		return status
 	}
 
-	if err != nil {
-		c.log.Log(logger.LevelError, "error reading manifest: "+err.Error())
-		return exit.ManifestRead
+	if !c.c.dryRun || c.c.resume {
+		if len(c.c.journal) < 1 {
+			c.c.journal = filepath.Join(c.c.log.dir, JournalName)
+		}
+
+		c.journal, err = journal.Open(c.c.journal)
+		if err != nil {
+			c.log.Log(logger.LevelError, "error opening journal: "+err.Error())
+			return exit.JournalError
+		}
+		c.log.Log(logger.LevelINFO, "Recording outcomes to "+c.journal.Name()+".")
 	}
 
 	return exit.RDY
 }
 
 func (c *CmdMigrate) Task() int {
-	defer c.closeM()
+	defer c.m.Close()
 	defer c.log.Close()
+	if c.journal != nil {
+		defer c.journal.Close()
+	}
 
 	if c.c.dryRun {
 		fmt.Println("Running in dry run mode. Check logs.")
 		c.log.Log(logger.LevelINFO, "Running in dry run mode.")
+		c.c.plan = &runPlan{}
+	}
+
+	c.log.Log(logger.LevelINFO, "Copying files with "+c.c.backend.Name()+".")
+	if c.c.jobs > 1 {
+		c.log.Log(logger.LevelINFO, fmt.Sprintf("Using %d concurrent workers.", c.c.jobs))
+	}
+
+	if c.c.preflight {
+		status := c.preflight()
+		if status != exit.Norm {
+			return status
+		}
 	}
 
-	c.log.Log(logger.LevelINFO, "Copying files with "+c.c.util+".")
+	entries := make(chan manifestEntry)
+	var wg sync.WaitGroup
+	summary := runSummary{dryRun: c.c.dryRun}
+
+	for i := 0; i < c.c.jobs; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
 
-	lineN := 1
+			for e := range entries {
+				summary.add(e, c.process(e))
+			}
+		}()
+	}
+
+	status := exit.Norm
 	eof := false
 
 	for !eof {
-		src, dest, err := readManifestEntry(c.m, &lineN)
+		e, err := readManifestEntry(c.m)
 		if err != nil {
 			if errors.Is(err, errManifest) {
 				c.log.Log(logger.LevelWARN, "Error: "+err.Error())
+				summary.addInvalid(err)
 			} else if errors.Is(err, io.EOF) {
 				eof = true
 			} else {
-				return exit.ManifestRead
+				c.log.Log(logger.LevelError, "Error reading manifest: "+err.Error())
+				status = exit.ManifestRead
+				break
 			}
 
 			continue
 		}
 
-		copy(c.log, c.c, src, dest)
+		logExpansion(c.log, e)
+		logRewrite(c.log, e)
+		entries <- e
 	}
 
-	return exit.Norm
-}
+	close(entries)
+	wg.Wait()
 
-// normPaths normalizes paths by converting them to absolute paths.
-// Trailing slashes are reintroduced into the paths after normalizations.
-func normPaths(src, dest string) (string, string, bool) {
-	var err error
+	if c.c.plan != nil {
+		c.c.plan.WriteTable(os.Stdout)
 
-	aSrc, err := filepath.Abs(src)
-	if err != nil {
-		return "", "", false
-	}
+		paths, err := writePlanFiles(c.c.plan, c.log.DirAbs())
+		if err != nil {
+			c.log.Log(logger.LevelError, "Error writing plan: "+err.Error())
+		}
 
-	aDest, err := filepath.Abs(dest)
-	if err != nil {
-		return "", "", false
+		if len(paths) > 0 {
+			msg := "Plan written to " + strings.Join(paths, " and ") + "."
+			fmt.Println(msg)
+			c.log.Log(logger.LevelINFO, msg)
+		}
 	}
 
-	// reintroduce trailing slashes
-	src = PreserveTrailingSlash(src, aSrc)
-	dest = PreserveTrailingSlash(dest, aDest)
+	fmt.Print(summary.String())
+	c.log.WriteString(summary.String())
 
-	return src, dest, true
-}
-
-// openManifest opens the manifest and creates a buffered reader.
-func openManifest(log *logger.Logger, manifest string) (*bufio.Reader, func() error, error) {
-	m, err := os.Open(manifest)
-	if err != nil {
-		return nil, nil, err
+	if status != exit.Norm {
+		return status
 	}
 
-	return bufio.NewReader(m), m.Close, nil
+	return summary.status()
 }
 
-// manifestFromArgs returns a manifest buffered reader from the given src and dest paths.
-func manifestFromArgs(log *logger.Logger, src, dest string) (*bufio.Reader, func() error, error) {
-	var buffer bytes.Buffer
-	closeFn := func() error {
-		buffer.Reset()
-
-		return nil
+// process copies the manifest entry and records its outcome in the journal.
+// When resuming, entries recorded as succeeded are skipped.
+func (c *CmdMigrate) process(e manifestEntry) copyStatus {
+	if c.c.resume && c.journal.Succeeded(e.line, e.src, e.dest) {
+		c.log.Log(logger.LevelINFO, fmt.Sprintf("Skipping %s: %s => %s already succeeded.",
+			e.location(), e.src, e.dest))
+		return copyResumed
 	}
 
-	buffer.WriteString(src + ManifestSep + dest)
+	status := copy(c.log, c.c, e)
 
-	return bufio.NewReader(&buffer), closeFn, nil
-}
+	for retry := 1; status == copyPartial && retry <= c.c.retryPartial; retry++ {
+		c.log.Log(logger.LevelINFO, fmt.Sprintf("Retrying %s (attempt %d of %d).",
+			e.location(), retry, c.c.retryPartial))
 
-// readManifestEntry reads and parses the next line of the manifest.
-// The normalized source path and destination path are returned.
-//
-// readManifestEntry reads whole lines from the buffered reader when possible.
-// If the lines are too long for a single read, multiple reads executed until the whole line has
-// been read.
-//
-// lineN is advanced after a successful read and parse.
-func readManifestEntry(m *bufio.Reader, lineN *int) (string, string, error) {
-	var lineBuilder strings.Builder
-	var lineBuffer []byte
-	var err error
-	isPrefix := true
+		status = copy(c.log, c.c, e)
+	}
 
-	for isPrefix {
-		lineBuffer, isPrefix, err = m.ReadLine()
+	if !c.c.dryRun {
+		err := c.journal.Record(e.line, e.src, e.dest, status.String())
 		if err != nil {
-			return "", "", err
+			c.log.Log(logger.LevelError, "Error recording "+e.location()+" to journal: "+err.Error())
 		}
-
-		lineBuilder.Write(lineBuffer)
 	}
 
-	defer func() { (*lineN)++ }()
+	return status
+}
 
-	if lineBuilder.Len() < 1 {
-		return "", "", newManifestErr(*lineN, "empty line")
+// copy copies the manifest entry with the backend and returns the outcome.
+// In dry mode, it instead prints the commands and reports the entry as skipped.
+func copy(log *logger.Logger, config migrateConf, e manifestEntry) copyStatus {
+	src := e.src
+	fields := logger.Fields{
+		Line: e.line,
+		Src:  e.src,
+		Dest: e.dest,
+		Util: config.backend.Name(),
 	}
 
-	mapping := strings.Split(lineBuilder.String(), ManifestSep)
-	if len(mapping) < 2 || len(mapping[0]) < 1 || len(mapping[1]) < 1 {
-		return "", "", newManifestErr(*lineN, "syntax error")
-	}
+	// entries with their own arguments get their own backend
+	backend := config.backend
+	if e.argv != nil {
+		backend = newBackend(config.util)
 
-	src, dest, norm := normPaths(mapping[0], mapping[1])
-	if !norm {
-		return "", "", newManifestErr(*lineN, "cannot normalize paths for "+src+" => "+dest)
+		err := backend.Prepare(config.util, e.argv)
+		if err != nil {
+			log.LogWith(logger.LevelError, "Error preparing "+backend.Name()+" for "+src+".", fields)
+			log.File(src).LogWith(logger.LevelError, "Error preparing "+backend.Name()+": "+err.Error(), fields)
+
+			return copyFailed
+		}
 	}
 
-	return src, dest, nil
-}
+	if config.dryRun {
+		argv := backend.Command(e.src, e.dest)
+		log.LogWith(logger.LevelINFO, "  "+joinArgs(argv), fields)
 
-// readManifest parses the manifest and generates source->dest map.
-//
-// Deprecated: readManifest has been refactored to partially fix [#2], but it still stores the
-// whole mappings into memory.
-// Parsing the manifest should truly be done line-by-line by calling openManifest and
-// readManifestEntry.
-//
-// [#2]: https://github.com/Ghifari160/migrate/issues/2
-func readManifest(log *logger.Logger, manifest string) (map[string]string, error) {
-	m, closeM, err := openManifest(log, manifest)
-	if err != nil {
-		return nil, err
+		if config.plan != nil {
+			config.plan.add(planManifestEntry(e, argv))
+		}
+
+		return copySkipped
 	}
-	defer closeM()
 
-	mappings := make(map[string]string)
-	lineN := 1
-	eof := false
+	log.LogWith(logger.LevelINFO, "Copying "+e.src+" to "+e.dest+".", fields)
 
-	for !eof {
-		src, dest, err := readManifestEntry(m, &lineN)
-		if err != nil {
-			if errors.Is(err, errManifest) {
-				log.Log(logger.LevelWARN, fmt.Sprintf("Error: %s. Skipping.", err))
-			} else if errors.Is(err, io.EOF) {
-				eof = true
-			} else {
-				return nil, err
-			}
+	start := time.Now()
+	r := backend.Copy(e.src, e.dest)
 
-			continue
-		}
+	fields.ExitStatus = &r.Code
+	fields.Duration = time.Since(start)
+	log.LogWith(logger.LevelINFO, fmt.Sprintf("Finished copying %s in %s.", e.src, fields.Duration), fields)
 
-		mappings[src] = dest
-	}
+	var level logger.LogLevel
+	var entry string
+	var status copyStatus
 
-	return mappings, nil
-}
+	switch r.Class {
+	case ExitSuccess:
+		return copySucceeded
 
-// copy copies the file by executing the copying utility. In dry mode, it instead prints the exec
-// commands.
-func copy(log *logger.Logger, config migrateConf, src, dest string) {
-	if config.dryRun {
-		entry := fmt.Sprintf("  %s %s %s %s", config.util, config.args, src, dest)
-		log.Log(logger.LevelINFO, entry)
-		return
-	}
+	case ExitWarning:
+		level = logger.LevelWARN
+		entry = fmt.Sprintf("Warnings copying %s", src)
+		status = copySucceeded
 
-	log.Log(logger.LevelINFO, "Copying "+src+" to "+dest+".")
+	case ExitPartial:
+		level = logger.LevelError
+		entry = fmt.Sprintf("Partially copied %s", src)
+		status = copyPartial
 
-	cmd := exec.Command(config.util, config.args, src, dest)
-	stdout, err := cmd.Output()
-	if err != nil {
-		entry := fmt.Sprintf("Error copying %s", src)
+	default:
+		level = logger.LevelError
+		entry = fmt.Sprintf("Error copying %s", src)
+		status = copyFailed
+	}
 
-		log.Log(logger.LevelError, entry+".")
+	log.LogWith(level, entry+".", fields)
 
-		log.File(src).Log(logger.LevelError, entry+": "+err.Error())
-		log.File(src).Log(logger.LevelError, config.util+" output:")
-		log.File(src).Write(stdout)
+	if r.Err != nil {
+		log.File(src).LogWith(level, entry+": "+r.Err.Error(), fields)
 	}
+
+	if len(r.Output) > 0 {
+		log.File(src).Log(level, config.backend.Name()+" output:")
+		log.File(src).Write(r.Output)
+	}
+
+	return status
 }
 
 func (c *CmdMigrate) Usage() string {
-	usage := "  migrate run [FLAGS] SRC DEST\n  migrate run [FLAGS] [MANIFEST]\n"
+	usage := "  migrate run [FLAGS] SRC DEST\n  migrate run [FLAGS] [MANIFEST]\n  migrate run -resume [FLAGS] [MANIFEST]\n"
 
 	if c.printFlags {
 		usage += "\nFLAGS:\n\n" + PrintDefaults(c.f)
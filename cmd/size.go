@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+)
+
+// pathSize returns the total size of the regular files at path.
+// Directories are walked recursively, without following symbolic links.
+func pathSize(path string) (int64, error) {
+	size, _, err := pathUsage(path, false)
+	return size, err
+}
+
+// pathUsage returns the total size of the regular files at path, and the number of files,
+// directories, and symbolic links.
+// If contents is true, the directory at path is not counted, only its contents.
+// Directories are walked recursively, without following symbolic links.
+func pathUsage(path string, contents bool) (int64, int64, error) {
+	var size, files int64
+	root := filepath.Clean(path)
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !contents || path != root {
+			files++
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		size += info.Size()
+
+		return nil
+	})
+
+	return size, files, err
+}
+
+// formatBytes formats the size in bytes with binary unit prefixes.
+func formatBytes(size uint64) string {
+	const unit = 1024
+
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := uint64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ghifari160/migrate/internal/exit"
+)
+
+// copyStatus is the outcome of processing a manifest entry.
+type copyStatus int
+
+const (
+	copySucceeded copyStatus = iota
+	copyFailed
+	copySkipped
+	copyPartial
+	// copyResumed is an entry not copied again as it succeeded in an earlier run.
+	copyResumed
+)
+
+func (s copyStatus) String() string {
+	switch s {
+	case copySucceeded:
+		return "succeeded"
+
+	case copyFailed:
+		return "failed"
+
+	case copySkipped:
+		return "skipped"
+
+	case copyPartial:
+		return "partial"
+
+	case copyResumed:
+		return "resumed"
+
+	default:
+		return "unknown"
+	}
+}
+
+// runSummary tallies the outcome of every manifest entry processed by a run.
+// It is concurrency-safe through the use of [sync.Mutex].
+type runSummary struct {
+	m         sync.Mutex
+	dryRun    bool
+	succeeded int
+	resumed   int
+	failed    int
+	partial   int
+	skipped   int
+	invalid   []error
+	failures  []failure
+}
+
+// failure is a manifest entry that failed to copy completely.
+type failure struct {
+	entry  manifestEntry
+	status copyStatus
+}
+
+// add records the outcome of the manifest entry.
+func (s *runSummary) add(e manifestEntry, status copyStatus) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	switch status {
+	case copySucceeded:
+		s.succeeded++
+
+	case copyResumed:
+		s.resumed++
+
+	case copyFailed:
+		s.failed++
+		s.failures = append(s.failures, failure{entry: e, status: status})
+
+	case copyPartial:
+		s.partial++
+		s.failures = append(s.failures, failure{entry: e, status: status})
+
+	default:
+		s.skipped++
+	}
+}
+
+// addInvalid records the manifest error of an entry that could not be read.
+func (s *runSummary) addInvalid(err error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.invalid = append(s.invalid, err)
+}
+
+// String returns the summary as a human-readable text.
+// Invalid entries are listed in reading order, followed by failed entries in manifest order,
+// regardless of their completion order.
+func (s *runSummary) String() string {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	var summary strings.Builder
+
+	summary.WriteString(fmt.Sprintf(
+		"Summary: %d succeeded, %d already succeeded, %d partial, %d failed, %d skipped, %d invalid.\n",
+		s.succeeded, s.resumed, s.partial, s.failed, s.skipped, len(s.invalid)))
+
+	for _, err := range s.invalid {
+		summary.WriteString(fmt.Sprintf("  Invalid: %s\n", err.Error()))
+	}
+
+	sort.Slice(s.failures, func(i, j int) bool {
+		return s.failures[i].entry.seq < s.failures[j].entry.seq
+	})
+
+	for _, f := range s.failures {
+		label := "Failed"
+		if f.status == copyPartial {
+			label = "Partially copied"
+		}
+
+		summary.WriteString(fmt.Sprintf("  %s at %s: %s => %s\n",
+			label, f.entry.location(), f.entry.src, f.entry.dest))
+	}
+
+	return summary.String()
+}
+
+// status returns the exit code for the tallied outcomes.
+// Invalid entries count as failures, while entries that succeeded in an earlier run count as
+// successes.
+// Dry runs copy nothing, so invalid entries are reported as an invalid manifest instead.
+func (s *runSummary) status() int {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.dryRun && len(s.invalid) > 0 {
+		return exit.InvalidManifest
+	}
+
+	if s.failed+s.partial+len(s.invalid) < 1 {
+		return exit.Norm
+	}
+
+	if s.succeeded+s.resumed < 1 {
+		return exit.TotalFailure
+	}
+
+	return exit.PartialFailure
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/ghifari160/migrate/internal/exit"
+)
+
+func TestRunSummaryStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []copyStatus
+		invalid  int
+		dryRun   bool
+		want     int
+	}{
+		{name: "empty", want: exit.Norm},
+		{name: "succeeded", statuses: []copyStatus{copySucceeded, copySkipped}, want: exit.Norm},
+		{name: "all skipped", statuses: []copyStatus{copySkipped}, want: exit.Norm},
+		{name: "failed", statuses: []copyStatus{copySucceeded, copyFailed}, want: exit.PartialFailure},
+		{name: "partial", statuses: []copyStatus{copyPartial}, want: exit.TotalFailure},
+		{name: "resumed", statuses: []copyStatus{copyResumed, copyResumed, copyFailed},
+			want: exit.PartialFailure},
+		{name: "all resumed", statuses: []copyStatus{copyResumed}, want: exit.Norm},
+		{name: "all invalid", invalid: 2, want: exit.TotalFailure},
+		{name: "dry run", statuses: []copyStatus{copySkipped}, dryRun: true, want: exit.Norm},
+		{name: "dry run invalid", statuses: []copyStatus{copySkipped}, invalid: 1, dryRun: true,
+			want: exit.InvalidManifest},
+		{name: "some invalid", statuses: []copyStatus{copySucceeded}, invalid: 1,
+			want: exit.PartialFailure},
+	}
+
+	for _, test := range tests {
+		summary := runSummary{dryRun: test.dryRun}
+
+		for i, status := range test.statuses {
+			summary.add(manifestEntry{seq: i}, status)
+		}
+
+		for i := 0; i < test.invalid; i++ {
+			summary.addInvalid(newManifestErr(i+1, "invalid"))
+		}
+
+		if got := summary.status(); got != test.want {
+			t.Errorf("%s: status = %d, want %d", test.name, got, test.want)
+		}
+	}
+}
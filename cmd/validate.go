@@ -0,0 +1,513 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/ghifari160/migrate/internal/exit"
+	"github.com/ghifari160/migrate/internal/logger"
+)
+
+// Validation report formats.
+const (
+	ReportText = "text"
+	ReportJSON = "json"
+)
+
+// ReportJSONName is the name of the JSON validation report written to the logging directory when
+// no report path is given.
+const ReportJSONName = "validation.json"
+
+// Validation issue severities.
+// Only errors fail validation.
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+)
+
+// maxNameLen is the default maximum length of a path component, in bytes.
+const maxNameLen = 255
+
+// maxLengthIssues is the number of paths reported by name for each entry exceeding length limits.
+const maxLengthIssues = 3
+
+type CmdValidate struct {
+	f          *flag.FlagSet
+	printFlags bool
+	m          *manifestReader
+	c          validateConf
+	log        *logger.Logger
+}
+
+type validateConf struct {
+	log             logConf
+	manifest        manifestConf
+	output          string
+	report          string
+	caseInsensitive bool
+	maxName         int
+	maxPath         int
+}
+
+// validationIssue is a problem found in a manifest entry.
+type validationIssue struct {
+	Line     int    `json:"line,omitempty"`
+	File     string `json:"file,omitempty"`
+	Severity string `json:"severity"`
+	Check    string `json:"check"`
+	Message  string `json:"message"`
+	Src      string `json:"src,omitempty"`
+	Dest     string `json:"dest,omitempty"`
+
+	// seq is the position of the entry in reading order, used to sort the issues.
+	seq int
+}
+
+// validationReport is the outcome of validating a manifest.
+type validationReport struct {
+	Manifest string            `json:"manifest,omitempty"`
+	Entries  int               `json:"entries"`
+	Errors   int               `json:"errors"`
+	Warnings int               `json:"warnings"`
+	Issues   []validationIssue `json:"issues"`
+}
+
+func NewCmdValidate() Cmd {
+	return &CmdValidate{
+		f: NewFlagSet("validate"),
+		c: validateConf{
+			output:  ReportText,
+			maxName: maxNameLen,
+			maxPath: maxPathLen(),
+		},
+	}
+}
+
+func (c *CmdValidate) Command(args []string) int {
+	var err error
+
+	c.f.StringVar(&c.c.output, "output", c.c.output, "Report format (text, json).")
+	c.f.StringVar(&c.c.report, "report", c.c.report, "Report path. Defaults to stdout for text reports, "+
+		"and to "+ReportJSONName+" in the logging directory for JSON reports.")
+	c.f.BoolVar(&c.c.caseInsensitive, "case-insensitive", c.c.caseInsensitive, "Compare paths ignoring case, "+
+		"as on case-insensitive file systems (e.g. NTFS, SMB shares).")
+	c.f.IntVar(&c.c.maxName, "max-name", c.c.maxName, "Maximum length of a destination path component, in bytes.")
+	c.f.IntVar(&c.c.maxPath, "max-path", c.c.maxPath, "Maximum length of a destination path, in bytes.")
+	manifestFlags(c.f, &c.c.manifest)
+	logFlags(c.f, &c.c.log)
+
+	err = c.f.Parse(args)
+	if err != nil {
+		c.printFlags = true
+		return exit.Usage
+	}
+
+	c.c.output = strings.ToLower(c.c.output)
+	if c.c.output != ReportText && c.c.output != ReportJSON {
+		c.printFlags = true
+		return exit.Usage
+	}
+
+	if c.c.maxName < 1 || c.c.maxPath < 1 {
+		c.printFlags = true
+		return exit.Usage
+	}
+
+	if !c.c.log.valid() {
+		c.printFlags = true
+		return exit.Usage
+	}
+
+	c.log, err = openLogs(c.c.log)
+	if err != nil {
+		return exit.LogError
+	}
+	fmt.Println("Logging to " + c.log.DirAbs() + ".")
+
+	// stdout also carries status messages, which would make the JSON report unreadable
+	if c.c.output == ReportJSON && len(c.c.report) < 1 {
+		c.c.report = filepath.Join(c.log.DirAbs(), ReportJSONName)
+	}
+
+	var status int
+	c.m, status = openManifestArgs(c.log, c.f.Args(), c.c.manifest)
+	if status != exit.RDY {
+		return status
+	}
+
+	return exit.RDY
+}
+
+func (c *CmdValidate) Task() int {
+	defer c.m.Close()
+	defer c.log.Close()
+
+	c.log.Log(logger.LevelINFO, "Validating manifest.")
+
+	v := newValidator(c.c)
+	v.report.Manifest = c.m.name
+
+	eof := false
+
+	for !eof {
+		e, err := readManifestEntry(c.m)
+		if err != nil {
+			var mErr *manifestErr
+
+			if errors.As(err, &mErr) {
+				v.add(validationIssue{
+					Line:     mErr.line,
+					File:     mErr.file,
+					Severity: severityError,
+					Check:    "syntax",
+					Message:  mErr.msg,
+					seq:      v.seq,
+				})
+			} else if errors.Is(err, io.EOF) {
+				eof = true
+			} else {
+				c.log.Log(logger.LevelError, "Error reading manifest: "+err.Error())
+				return exit.ManifestRead
+			}
+
+			continue
+		}
+
+		v.validate(e)
+	}
+
+	v.checkOverlaps()
+	v.sort()
+
+	for _, issue := range v.report.Issues {
+		level := logger.LevelWARN
+		if issue.Severity == severityError {
+			level = logger.LevelError
+		}
+
+		c.log.LogWith(level, issue.String(), logger.Fields{Line: issue.Line, Src: issue.Src, Dest: issue.Dest})
+	}
+
+	err := c.writeReport(v.report)
+	if err != nil {
+		c.log.Log(logger.LevelError, "Error writing report: "+err.Error())
+		return exit.ManifestWrite
+	}
+
+	if len(c.c.report) > 0 {
+		msg := "Report written to " + c.c.report + "."
+		fmt.Println(msg)
+		c.log.Log(logger.LevelINFO, msg)
+	}
+
+	if v.report.Errors > 0 {
+		return exit.InvalidManifest
+	}
+
+	return exit.Norm
+}
+
+// writeReport writes the report in the configured format to the report path or stdout.
+func (c *CmdValidate) writeReport(report validationReport) error {
+	var w io.Writer = os.Stdout
+
+	if len(c.c.report) > 0 {
+		file, err := os.Create(c.c.report)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		w = file
+	}
+
+	if c.c.output == ReportJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(report)
+	}
+
+	var text strings.Builder
+
+	for _, issue := range report.Issues {
+		text.WriteString(issue.String() + "\n")
+	}
+
+	text.WriteString(fmt.Sprintf("Summary: %d entries, %d errors, %d warnings.\n",
+		report.Entries, report.Errors, report.Warnings))
+
+	_, err := io.WriteString(w, text.String())
+
+	return err
+}
+
+func (i validationIssue) String() string {
+	location := fmt.Sprintf("line %d", i.Line)
+	if len(i.File) > 0 {
+		location += " of " + i.File
+	}
+
+	return fmt.Sprintf("%s: %s: %s (%s)", location, i.Severity, i.Message, i.Check)
+}
+
+// entryPath is a path of a manifest entry, indexed for overlap detection.
+type entryPath struct {
+	key  string
+	path string
+	e    manifestEntry
+}
+
+// validator checks manifest entries and collects the issues found.
+type validator struct {
+	config  validateConf
+	report  validationReport
+	srcs    []entryPath
+	targets []entryPath
+	seen    map[string]manifestEntry
+	// seq is the position of the last entry validated.
+	seq int
+}
+
+func newValidator(config validateConf) *validator {
+	return &validator{
+		config: config,
+		report: validationReport{Issues: make([]validationIssue, 0)},
+		seen:   make(map[string]manifestEntry),
+	}
+}
+
+// add records the issue.
+func (v *validator) add(issue validationIssue) {
+	if issue.Severity == severityError {
+		v.report.Errors++
+	} else {
+		v.report.Warnings++
+	}
+
+	v.report.Issues = append(v.report.Issues, issue)
+}
+
+// addEntry records an issue with the manifest entry.
+func (v *validator) addEntry(e manifestEntry, severity, check, msg string) {
+	v.add(validationIssue{
+		Line:     e.line,
+		File:     e.file,
+		Severity: severity,
+		Check:    check,
+		Message:  msg,
+		Src:      e.src,
+		Dest:     e.dest,
+		seq:      e.seq,
+	})
+}
+
+// sort sorts the issues in manifest order.
+func (v *validator) sort() {
+	sort.SliceStable(v.report.Issues, func(i, j int) bool {
+		return v.report.Issues[i].seq < v.report.Issues[j].seq
+	})
+}
+
+// pathKey returns the key of path used for comparisons.
+// Separators are replaced with NUL so that paths sort directly before their descendants.
+func (v *validator) pathKey(path string) string {
+	if v.config.caseInsensitive {
+		path = strings.ToLower(path)
+	}
+
+	return strings.ReplaceAll(filepath.Clean(path), string(filepath.Separator), "\x00")
+}
+
+// validate checks the manifest entry.
+func (v *validator) validate(e manifestEntry) {
+	v.report.Entries++
+	v.seq = e.seq
+
+	src := filepath.Clean(e.src)
+	target := entryTarget(e.src, e.dest)
+
+	s, err := os.Stat(src)
+	if err != nil {
+		v.addEntry(e, severityError, "missing-source", "Cannot read source: "+err.Error())
+	} else if s.IsDir() && isWithin(v.pathKey(target), v.pathKey(src)) {
+		v.addEntry(e, severityError, "nested-destination", "Destination "+target+" is inside its source")
+	}
+
+	if msg := destinationWritable(target); len(msg) > 0 {
+		v.addEntry(e, severityError, "unwritable-destination", msg)
+	}
+
+	if s != nil {
+		v.checkLengths(e, src, target)
+	}
+
+	key := v.pathKey(src)
+	if other, found := v.seen[key]; found {
+		v.addEntry(e, severityWarning, "duplicate-source", "Source is also copied at "+other.location())
+	} else {
+		v.seen[key] = e
+		v.srcs = append(v.srcs, entryPath{key: key, path: src, e: e})
+	}
+
+	v.targets = append(v.targets, entryPath{key: v.pathKey(target), path: target, e: e})
+}
+
+// destinationWritable checks if target can be created.
+// It returns a description of the problem, or an empty string if there is none.
+// The nearest existing ancestor of target must be a directory that the current user can write to.
+func destinationWritable(target string) string {
+	dir, err := existingAncestor(filepath.Dir(target))
+	if err != nil {
+		return "Cannot read destination parent of " + target + ": " + err.Error()
+	}
+
+	d, err := os.Stat(dir)
+	if err != nil {
+		return "Cannot read destination parent " + dir + ": " + err.Error()
+	}
+
+	if !d.IsDir() {
+		return "Destination parent " + dir + " is not a directory"
+	}
+
+	err = checkWritable(dir)
+	if err != nil {
+		return "Destination parent " + dir + " is not writable: " + err.Error()
+	}
+
+	return ""
+}
+
+// checkLengths checks the paths the source will be copied to against the length limits.
+func (v *validator) checkLengths(e manifestEntry, src, target string) {
+	var long []string
+	count := 0
+
+	check := func(path string) {
+		tooLong := len(path) > v.config.maxPath
+		for _, name := range strings.Split(path, string(filepath.Separator)) {
+			tooLong = tooLong || len(name) > v.config.maxName
+		}
+
+		if tooLong {
+			count++
+			if len(long) < maxLengthIssues {
+				long = append(long, path)
+			}
+		}
+	}
+
+	check(target)
+
+	filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == src {
+			return nil
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return nil
+		}
+
+		check(filepath.Join(target, rel))
+
+		return nil
+	})
+
+	if count > 0 {
+		v.addEntry(e, severityError, "path-length", fmt.Sprintf(
+			"%d destination paths exceed the limits of %d bytes per name or %d bytes per path, including %s",
+			count, v.config.maxName, v.config.maxPath, strings.Join(long, ", ")))
+	}
+}
+
+// checkOverlaps checks for entries copying to the same destination, and for entries whose
+// sources or destinations are nested inside those of other entries.
+func (v *validator) checkOverlaps() {
+	nested(v.targets, func(inner, outer entryPath) {
+		if inner.key == outer.key {
+			v.addEntry(inner.e, severityError, "destination-conflict",
+				"Destination "+inner.path+" is also the destination of "+outer.e.location())
+			return
+		}
+
+		v.addEntry(inner.e, severityWarning, "overlap",
+			"Destination "+inner.path+" is inside the destination of "+outer.e.location())
+	})
+
+	nested(v.srcs, func(inner, outer entryPath) {
+		v.addEntry(inner.e, severityWarning, "overlap",
+			"Source "+inner.path+" is also copied by "+outer.e.location())
+	})
+}
+
+// nested calls fn for each path equal to or inside another path, with the closest such path.
+// Paths are visited in sorted order, with equal paths in their original order.
+func nested(paths []entryPath, fn func(inner, outer entryPath)) {
+	sort.SliceStable(paths, func(i, j int) bool {
+		return paths[i].key < paths[j].key
+	})
+
+	stack := make([]entryPath, 0)
+
+	for _, p := range paths {
+		for len(stack) > 0 && !isWithin(p.key, stack[len(stack)-1].key) {
+			stack = stack[:len(stack)-1]
+		}
+
+		if len(stack) > 0 {
+			fn(p, stack[len(stack)-1])
+		}
+
+		stack = append(stack, p)
+	}
+}
+
+// isWithin checks if the path key is equal to or inside the dir key.
+func isWithin(path, dir string) bool {
+	if path == dir {
+		return true
+	}
+
+	if !strings.HasSuffix(dir, "\x00") {
+		dir += "\x00"
+	}
+
+	return strings.HasPrefix(path, dir)
+}
+
+// maxPathLen returns the default maximum length of a path on the current platform, in bytes.
+func maxPathLen() int {
+	switch runtime.GOOS {
+	case "windows":
+		return 260
+
+	case "darwin":
+		return 1024
+
+	default:
+		return 4096
+	}
+}
+
+func (c *CmdValidate) Usage() string {
+	usage := "  migrate validate [FLAGS] SRC DEST\n  migrate validate [FLAGS] [MANIFEST]\n"
+
+	if c.printFlags {
+		usage += "\nFLAGS:\n\n" + PrintDefaults(c.f)
+	}
+
+	return usage
+}
+
+func (c *CmdValidate) private() {}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+)
+
+// varsFlag collects repeated NAME=VALUE flags into variables.
+// It implements flag.Value.
+type varsFlag map[string]string
+
+func (v varsFlag) String() string {
+	pairs := make([]string, 0, len(v))
+	for name, value := range v {
+		pairs = append(pairs, name+"="+value)
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func (v varsFlag) Set(pair string) error {
+	i := strings.Index(pair, "=")
+	if i < 0 {
+		return errors.New("expected NAME=VALUE")
+	}
+
+	name := pair[:i]
+	if !isVarName(name) {
+		return errors.New("invalid variable name " + name)
+	}
+
+	v[name] = pair[i+1:]
+
+	return nil
+}
+
+// expandVars replaces ${NAME} references in s with the values returned by lookup.
+// $${ escapes a literal ${.
+// References to undefined variables are errors.
+func expandVars(s string, lookup func(name string) (string, bool)) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	var expanded strings.Builder
+
+	for len(s) > 0 {
+		i := strings.Index(s, "$")
+		if i < 0 {
+			expanded.WriteString(s)
+			break
+		}
+
+		expanded.WriteString(s[:i])
+		s = s[i:]
+
+		switch {
+		case strings.HasPrefix(s, "$${"):
+			expanded.WriteString("${")
+			s = s[3:]
+
+		case strings.HasPrefix(s, "${"):
+			end := strings.Index(s, "}")
+			if end < 0 {
+				return "", errors.New("unterminated variable reference")
+			}
+
+			name := s[2:end]
+			if !isVarName(name) {
+				return "", errors.New("invalid variable name " + name)
+			}
+
+			value, found := lookup(name)
+			if !found {
+				return "", errors.New("undefined variable " + name)
+			}
+
+			expanded.WriteString(value)
+			s = s[end+1:]
+
+		default:
+			expanded.WriteString("$")
+			s = s[1:]
+		}
+	}
+
+	return expanded.String(), nil
+}
+
+// escapeVars escapes s so that expandVars returns it as is.
+func escapeVars(s string) string {
+	return strings.ReplaceAll(s, "${", "$${")
+}
+
+// isVarName checks if name is a valid variable name.
+// Variable names start with a letter or an underscore, followed by letters, digits, or underscores.
+func isVarName(name string) bool {
+	if len(name) < 1 {
+		return false
+	}
+
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case i > 0 && r >= '0' && r <= '9':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
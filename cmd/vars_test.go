@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestExpandVars(t *testing.T) {
+	vars := map[string]string{"ROOT": "/data", "EMPTY": ""}
+	lookup := func(name string) (string, bool) {
+		value, found := vars[name]
+		return value, found
+	}
+
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "/plain/path", want: "/plain/path"},
+		{in: "${ROOT}/a", want: "/data/a"},
+		{in: "${ROOT}${ROOT}", want: "/data/data"},
+		{in: "${EMPTY}", want: ""},
+		{in: "$${ROOT}", want: "${ROOT}"},
+		{in: "$$${ROOT}", want: "$${ROOT}"},
+		{in: "a$b", want: "a$b"},
+		{in: "${NOPE}", wantErr: true},
+		{in: "${ROOT", wantErr: true},
+		{in: "${1X}", wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := expandVars(test.in, lookup)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("expandVars(%q) = %q, want error", test.in, got)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("expandVars(%q) returned error: %v", test.in, err)
+			continue
+		}
+
+		if got != test.want {
+			t.Errorf("expandVars(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestEscapeVarsRoundTrip(t *testing.T) {
+	for _, path := range []string{"/a/${B}/c", "$${x}", "$", "${"} {
+		got, err := expandVars(escapeVars(path), func(string) (string, bool) { return "", false })
+		if err != nil {
+			t.Errorf("expanding escaped %q returned error: %v", path, err)
+			continue
+		}
+
+		if got != path {
+			t.Errorf("expanding escaped %q = %q", path, got)
+		}
+	}
+}
+
+// TestReadManifestEntryEmptyExpansion checks that paths expanding to nothing are manifest errors.
+func TestReadManifestEntryEmptyExpansion(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		vars     varsFlag
+	}{
+		{name: "empty -var src", manifest: "${X};/tmp/d\n", vars: varsFlag{"X": ""}},
+		{name: "empty @set dest", manifest: "@set Y=\nsrc;${Y}\n"},
+	}
+
+	for _, test := range tests {
+		m := newManifestReader(strings.NewReader(test.manifest), nil, manifestConf{format: FormatText, vars: test.vars})
+
+		_, err := readManifestEntry(m)
+		if !errors.Is(err, errManifest) {
+			t.Errorf("%s: got error %v, want manifest error", test.name, err)
+		}
+	}
+}
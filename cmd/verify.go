@@ -0,0 +1,365 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"flag"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ghifari160/migrate/internal/exit"
+	"github.com/ghifari160/migrate/internal/logger"
+)
+
+// hashes maps the name of each supported hash algorithm to its constructor.
+var hashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+type CmdVerify struct {
+	f          *flag.FlagSet
+	printFlags bool
+	m          *manifestReader
+	c          verifyConf
+	log        *logger.Logger
+}
+
+type verifyConf struct {
+	log      logConf
+	manifest manifestConf
+	hash     string
+}
+
+func NewCmdVerify() Cmd {
+	return &CmdVerify{
+		f: NewFlagSet("verify"),
+		c: verifyConf{
+			hash: "sha256",
+		},
+	}
+}
+
+func (c *CmdVerify) Command(args []string) int {
+	var err error
+
+	c.f.StringVar(&c.c.hash, "hash", c.c.hash, "Hash algorithm ("+hashNames()+").")
+	manifestFlags(c.f, &c.c.manifest)
+	logFlags(c.f, &c.c.log)
+
+	err = c.f.Parse(args)
+	if err != nil {
+		c.printFlags = true
+		return exit.Usage
+	}
+
+	c.c.hash = strings.ToLower(c.c.hash)
+	if _, valid := hashes[c.c.hash]; !valid {
+		c.printFlags = true
+		return exit.Usage
+	}
+
+	if !c.c.log.valid() {
+		c.printFlags = true
+		return exit.Usage
+	}
+
+	c.log, err = openLogs(c.c.log)
+	if err != nil {
+		return exit.LogError
+	}
+	fmt.Println("Logging to " + c.log.DirAbs() + ".")
+
+	var status int
+	c.m, status = openManifestArgs(c.log, c.f.Args(), c.c.manifest)
+	if status != exit.RDY {
+		return status
+	}
+
+	return exit.RDY
+}
+
+func (c *CmdVerify) Task() int {
+	defer c.m.Close()
+	defer c.log.Close()
+
+	c.log.Log(logger.LevelINFO, "Verifying files with "+c.c.hash+".")
+
+	var verified, mismatched, invalid int
+	eof := false
+
+	for !eof {
+		e, err := readManifestEntry(c.m)
+		if err != nil {
+			if errors.Is(err, errManifest) {
+				c.log.Log(logger.LevelWARN, "Error: "+err.Error())
+				invalid++
+			} else if errors.Is(err, io.EOF) {
+				eof = true
+			} else {
+				c.log.Log(logger.LevelError, "Error reading manifest: "+err.Error())
+				return exit.ManifestRead
+			}
+
+			continue
+		}
+
+		logExpansion(c.log, e)
+		logRewrite(c.log, e)
+		if verify(c.log, c.c, e) {
+			verified++
+		} else {
+			mismatched++
+		}
+	}
+
+	summary := fmt.Sprintf("Summary: %d verified, %d mismatched, %d invalid.\n",
+		verified, mismatched, invalid)
+	fmt.Print(summary)
+	c.log.WriteString(summary)
+
+	if mismatched > 0 {
+		return exit.VerifyMismatch
+	}
+
+	// Invalid entries were not checked, so the copies cannot be reported as verified.
+	if invalid > 0 {
+		return exit.InvalidManifest
+	}
+
+	return exit.Norm
+}
+
+// verify compares the source of the manifest entry against its copy and reports whether they
+// match.
+// The copy is located with the same trailing slash semantics used for copying.
+// If the entry carries an expected size or checksum, the copy is also checked against them.
+// Each mismatching file is logged to the log file of src.
+func verify(log *logger.Logger, config verifyConf, e manifestEntry) bool {
+	src, dest := e.src, e.dest
+	log.Log(logger.LevelINFO, "Verifying "+src+" against "+dest+".")
+
+	target, err := copyTarget(src, dest)
+	if err != nil {
+		log.Log(logger.LevelError, "Error verifying "+src+".")
+		log.File(src).Log(logger.LevelError, "Error locating copy: "+err.Error())
+
+		return false
+	}
+
+	root := filepath.Clean(src)
+	mismatches := 0
+
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		to := filepath.Join(target, rel)
+
+		msg, err := compareFile(config, path, to)
+		if err != nil {
+			return err
+		}
+
+		if len(msg) > 0 {
+			mismatches++
+			log.File(src).Log(logger.LevelError, "Mismatch for "+path+": "+msg)
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Log(logger.LevelError, "Error verifying "+src+".")
+		log.File(src).Log(logger.LevelError, "Error verifying: "+err.Error())
+
+		return false
+	}
+
+	msg, err := compareExpected(config, e, target)
+	if err != nil {
+		log.Log(logger.LevelError, "Error verifying "+src+".")
+		log.File(src).Log(logger.LevelError, "Error verifying: "+err.Error())
+
+		return false
+	}
+
+	if len(msg) > 0 {
+		mismatches++
+		log.File(src).Log(logger.LevelError, "Mismatch for "+target+": "+msg)
+	}
+
+	if mismatches > 0 {
+		log.Log(logger.LevelError, fmt.Sprintf("%d mismatches found for %s.", mismatches, src))
+		return false
+	}
+
+	return true
+}
+
+// compareFile compares src against dest.
+// It returns a description of the mismatch, or an empty string if they match.
+// Directories are compared by type, symbolic links by their targets, and regular files by size
+// and content hash.
+func compareFile(config verifyConf, src, dest string) (string, error) {
+	s, err := os.Lstat(src)
+	if err != nil {
+		return "", err
+	}
+
+	d, err := os.Lstat(dest)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "missing from destination", nil
+		}
+
+		return "", err
+	}
+
+	if s.Mode().Type() != d.Mode().Type() {
+		return fmt.Sprintf("type %s differs from destination type %s", s.Mode().Type(), d.Mode().Type()), nil
+	}
+
+	switch {
+	case s.Mode()&fs.ModeSymlink != 0:
+		sLink, err := os.Readlink(src)
+		if err != nil {
+			return "", err
+		}
+
+		dLink, err := os.Readlink(dest)
+		if err != nil {
+			return "", err
+		}
+
+		if sLink != dLink {
+			return "link target " + sLink + " differs from destination link target " + dLink, nil
+		}
+
+	case s.Mode().IsRegular():
+		if s.Size() != d.Size() {
+			return fmt.Sprintf("size %d differs from destination size %d", s.Size(), d.Size()), nil
+		}
+
+		sSum, err := hashFile(config.hash, src)
+		if err != nil {
+			return "", err
+		}
+
+		dSum, err := hashFile(config.hash, dest)
+		if err != nil {
+			return "", err
+		}
+
+		if !bytes.Equal(sSum, dSum) {
+			return fmt.Sprintf("%s %x differs from destination %s %x", config.hash, sSum, config.hash, dSum), nil
+		}
+	}
+
+	return "", nil
+}
+
+// compareExpected compares the copy at target against the size and checksum expected by the
+// manifest entry.
+// It returns a description of the mismatch, or an empty string if they match.
+// Expectations only apply to regular files.
+func compareExpected(config verifyConf, e manifestEntry, target string) (string, error) {
+	if e.size == nil && len(e.checksum) < 1 {
+		return "", nil
+	}
+
+	d, err := os.Stat(target)
+	if err != nil {
+		return "", err
+	}
+
+	if !d.Mode().IsRegular() {
+		return "", nil
+	}
+
+	if e.size != nil && *e.size != d.Size() {
+		return fmt.Sprintf("size %d differs from expected size %d", d.Size(), *e.size), nil
+	}
+
+	if len(e.checksum) > 0 {
+		algorithm := config.hash
+		expected := e.checksum
+		if i := strings.Index(expected, ":"); i >= 0 {
+			algorithm = strings.ToLower(expected[:i])
+			expected = expected[i+1:]
+		}
+
+		sum, err := hashFile(algorithm, target)
+		if err != nil {
+			return "", err
+		}
+
+		if !strings.EqualFold(fmt.Sprintf("%x", sum), expected) {
+			return fmt.Sprintf("%s %x differs from expected %s %s", algorithm, sum, algorithm, expected), nil
+		}
+	}
+
+	return "", nil
+}
+
+// hashFile returns the checksum of the file computed with the named hash algorithm.
+func hashFile(algorithm, path string) ([]byte, error) {
+	newHash, valid := hashes[algorithm]
+	if !valid {
+		return nil, errors.New("unsupported hash algorithm " + algorithm)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	h := newHash()
+
+	_, err = io.Copy(h, file)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+// hashNames returns the names of the supported hash algorithms as a comma-separated list.
+func hashNames() string {
+	names := make([]string, 0, len(hashes))
+	for name := range hashes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
+
+func (c *CmdVerify) Usage() string {
+	usage := "  migrate verify [FLAGS] SRC DEST\n  migrate verify [FLAGS] [MANIFEST]\n"
+
+	if c.printFlags {
+		usage += "\nFLAGS:\n\n" + PrintDefaults(c.f)
+	}
+
+	return usage
+}
+
+func (c *CmdVerify) private() {}
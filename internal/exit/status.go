@@ -10,6 +10,13 @@ const (
 	UtilNotFound
 	NotFound
 	LogError
+	PartialFailure
+	TotalFailure
+	JournalError
+	VerifyMismatch
+	DestConflict
+	InvalidManifest
+	InsufficientCapacity
 )
 
 // Message returns the user friendly error message for the given exit code.
@@ -39,6 +46,27 @@ func Message(code int) string {
 	case LogError:
 		return "Logging error"
 
+	case PartialFailure:
+		return "Some entries failed to copy"
+
+	case TotalFailure:
+		return "All entries failed to copy"
+
+	case JournalError:
+		return "Journal error"
+
+	case VerifyMismatch:
+		return "Verification failed"
+
+	case DestConflict:
+		return "Destination conflicts found"
+
+	case InvalidManifest:
+		return "Manifest is invalid"
+
+	case InsufficientCapacity:
+		return "Insufficient destination capacity"
+
 	default:
 		return "Unknown error"
 	}
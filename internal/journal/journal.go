@@ -0,0 +1,155 @@
+package journal
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+const dirPerm = fs.FileMode(0755)
+const journalPerm = fs.FileMode(0644)
+
+const fieldSep = "\t"
+
+// Journal records the outcome of each manifest entry, allowing interrupted runs to be resumed.
+// Each record is keyed by the manifest line number, the source path, and the destination path.
+// It is concurrency-safe through the use of [sync.Mutex].
+type Journal struct {
+	m       sync.Mutex
+	file    *os.File
+	records map[key]string
+}
+
+// key identifies a manifest entry.
+type key struct {
+	line int
+	src  string
+	dest string
+}
+
+// Open loads the records of the journal at path and opens it for appending.
+// If the journal does not exist, it is created.
+func Open(path string) (*Journal, error) {
+	j := Journal{
+		records: make(map[key]string),
+	}
+
+	err := j.load(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), dirPerm)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, journalPerm)
+	if err != nil {
+		return nil, err
+	}
+	j.file = file
+
+	return &j, nil
+}
+
+// load reads the existing records of the journal at path.
+// Later records of the same entry supersede earlier ones.
+// Malformed records, such as the partially written last record of an interrupted run, are ignored.
+func (j *Journal) load(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		k, status, ok := parseRecord(scanner.Text())
+		if !ok {
+			continue
+		}
+
+		j.records[k] = status
+	}
+
+	return scanner.Err()
+}
+
+// parseRecord parses a single journal record.
+func parseRecord(record string) (key, string, bool) {
+	fields := strings.Split(record, fieldSep)
+	if len(fields) != 4 {
+		return key{}, "", false
+	}
+
+	line, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return key{}, "", false
+	}
+
+	src, err := strconv.Unquote(fields[2])
+	if err != nil {
+		return key{}, "", false
+	}
+
+	dest, err := strconv.Unquote(fields[3])
+	if err != nil {
+		return key{}, "", false
+	}
+
+	return key{line: line, src: src, dest: dest}, fields[1], true
+}
+
+// Record appends the outcome of the manifest entry to the journal.
+func (j *Journal) Record(line int, src, dest, status string) error {
+	j.m.Lock()
+	defer j.m.Unlock()
+
+	j.records[key{line: line, src: src, dest: dest}] = status
+
+	record := fmt.Sprintf("%d%s%s%s%s%s%s\n", line, fieldSep, status, fieldSep,
+		strconv.Quote(src), fieldSep, strconv.Quote(dest))
+
+	_, err := j.file.WriteString(record)
+	return err
+}
+
+// Status returns the latest recorded outcome of the manifest entry.
+// If the entry has not been recorded, ok is false.
+func (j *Journal) Status(line int, src, dest string) (status string, ok bool) {
+	j.m.Lock()
+	defer j.m.Unlock()
+
+	status, ok = j.records[key{line: line, src: src, dest: dest}]
+	return
+}
+
+// Succeeded checks if the manifest entry has been recorded as succeeded.
+func (j *Journal) Succeeded(line int, src, dest string) bool {
+	status, ok := j.Status(line, src, dest)
+	return ok && status == StatusSucceeded
+}
+
+// Name returns the path of the journal.
+func (j *Journal) Name() string {
+	return j.file.Name()
+}
+
+// Close closes the underlying file.
+func (j *Journal) Close() error {
+	j.m.Lock()
+	defer j.m.Unlock()
+
+	return j.file.Close()
+}
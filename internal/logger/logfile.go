@@ -1,9 +1,11 @@
 package logger
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 )
@@ -14,13 +16,39 @@ type LogFile struct {
 	m      sync.Mutex
 	file   *os.File
 	name   string
+	format LogFormat
 	closed bool
 }
 
+// Fields holds the structured context of a log entry.
+// Fields are only written in the JSON format.
+type Fields struct {
+	Line       int
+	Src        string
+	Dest       string
+	Util       string
+	ExitStatus *int
+	Duration   time.Duration
+}
+
+// jsonEntry is a log entry in the JSON format.
+type jsonEntry struct {
+	Timestamp  string   `json:"timestamp"`
+	Level      LogLevel `json:"level"`
+	Message    string   `json:"message"`
+	Line       int      `json:"line,omitempty"`
+	Src        string   `json:"src,omitempty"`
+	Dest       string   `json:"dest,omitempty"`
+	Util       string   `json:"utility,omitempty"`
+	ExitStatus *int     `json:"exit_status,omitempty"`
+	Duration   float64  `json:"duration,omitempty"`
+}
+
 // openLogFile creates a LogFile and opens the underlying file.
-func openLogFile(path string) (*LogFile, error) {
+func openLogFile(path string, format LogFormat) (*LogFile, error) {
 	log := LogFile{
-		name: path,
+		name:   path,
+		format: format,
 	}
 
 	if filepath.Ext(path) != ".log" {
@@ -68,20 +96,28 @@ func (l *LogFile) Close() error {
 
 // Write implements io.Writer.
 // Writing to a closed file returns zero length and nil error.
+// In the JSON format, the entry is wrapped in an INFO log entry.
 func (l *LogFile) Write(entry []byte) (int, error) {
-	l.m.Lock()
-	defer l.m.Unlock()
-
-	if l.closed {
-		return 0, nil
+	if l.format == FormatJSON {
+		return l.writeJSON(string(entry))
 	}
 
-	return l.file.Write(entry)
+	return l.write(entry)
 }
 
 // WriteString implements io.StringWriter.
 // Writing to a closed file returns zero length and nil error.
+// In the JSON format, the entry is wrapped in an INFO log entry.
 func (l *LogFile) WriteString(entry string) (int, error) {
+	if l.format == FormatJSON {
+		return l.writeJSON(entry)
+	}
+
+	return l.write([]byte(entry))
+}
+
+// write writes the entry to the underlying file as is.
+func (l *LogFile) write(entry []byte) (int, error) {
 	l.m.Lock()
 	defer l.m.Unlock()
 
@@ -89,13 +125,49 @@ func (l *LogFile) WriteString(entry string) (int, error) {
 		return 0, nil
 	}
 
-	return l.file.WriteString(entry)
+	return l.file.Write(entry)
+}
+
+// writeJSON wraps the raw entry in an INFO log entry and writes it to the underlying file.
+// The length of the raw entry is returned on success.
+func (l *LogFile) writeJSON(entry string) (int, error) {
+	err := l.LogWith(LevelINFO, strings.TrimSuffix(entry, "\n"), Fields{})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(entry), nil
 }
 
 // Log logs an entry at the specified level.
 func (l *LogFile) Log(level LogLevel, entry string) error {
-	t := time.Now().Format("2006/01/02T15:04:05.000000")
+	return l.LogWith(level, entry, Fields{})
+}
+
+// LogWith logs an entry with its structured context at the specified level.
+func (l *LogFile) LogWith(level LogLevel, entry string, fields Fields) error {
+	t := time.Now()
+
+	if l.format != FormatJSON {
+		_, err := l.write([]byte(fmt.Sprintf("%s [%s] %s\n", t.Format("2006/01/02T15:04:05.000000"), level, entry)))
+		return err
+	}
+
+	line, err := json.Marshal(jsonEntry{
+		Timestamp:  t.Format(time.RFC3339Nano),
+		Level:      level,
+		Message:    entry,
+		Line:       fields.Line,
+		Src:        fields.Src,
+		Dest:       fields.Dest,
+		Util:       fields.Util,
+		ExitStatus: fields.ExitStatus,
+		Duration:   fields.Duration.Seconds(),
+	})
+	if err != nil {
+		return err
+	}
 
-	_, err := l.WriteString(fmt.Sprintf("%s [%s] %s\n", t, level, entry))
+	_, err = l.write(append(line, '\n'))
 	return err
 }
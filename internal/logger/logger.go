@@ -6,7 +6,9 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strconv"
 	"sync"
+	"time"
 )
 
 const (
@@ -15,19 +17,41 @@ const (
 	LevelError LogLevel = "ERROR"
 )
 
+const (
+	FormatText LogFormat = "text"
+	FormatJSON LogFormat = "json"
+)
+
 const fileDir = "files"
 const mainLog = "migrate.log"
+const latestLink = "latest"
+const runDirFormat = "2006-01-02T150405"
 
 const dirPerm = fs.FileMode(0755)
 const logPerm = fs.FileMode(0644)
 
 type LogLevel string
 
+// LogFormat is the format of log entries.
+type LogFormat string
+
+// ParseFormat parses the name of a log format.
+func ParseFormat(format string) (LogFormat, error) {
+	switch LogFormat(format) {
+	case FormatText, FormatJSON:
+		return LogFormat(format), nil
+
+	default:
+		return "", errors.New("unknown log format " + format)
+	}
+}
+
 // Logger implements a multi-file nested logging system.
 // It is concurrency-safe through the use of [sync.Mutex].
 // Individual logging files (including the main file) are instances of [logger.LogFile].
 type Logger struct {
 	dir        string
+	format     LogFormat
 	main       *LogFile
 	filesMutex sync.Mutex
 	files      map[string]*LogFile
@@ -52,8 +76,17 @@ func mkLogDir(path string) error {
 }
 
 // OpenLogs creates a new Logger, prepares the log directory, and opens the main log file.
+// Entries are logged in the text format.
 func OpenLogs(logDir string) (*Logger, error) {
-	l := Logger{}
+	return OpenLogsFormat(logDir, FormatText)
+}
+
+// OpenLogsFormat creates a new Logger, prepares the log directory, and opens the main log file.
+// Entries of the main log file and every per-file log file are logged in the given format.
+func OpenLogsFormat(logDir string, format LogFormat) (*Logger, error) {
+	l := Logger{
+		format: format,
+	}
 
 	logDir, err := filepath.Abs(logDir)
 	if err != nil {
@@ -66,7 +99,7 @@ func OpenLogs(logDir string) (*Logger, error) {
 	}
 	l.dir = logDir
 
-	f, err := openLogFile(filepath.Join(l.dir, mainLog))
+	f, err := openLogFile(filepath.Join(l.dir, mainLog), l.format)
 	if err != nil {
 		return nil, err
 	}
@@ -77,6 +110,79 @@ func OpenLogs(logDir string) (*Logger, error) {
 	return &l, nil
 }
 
+// OpenRunLogs creates a new Logger with a timestamped subdirectory of baseDir as its log
+// directory, so that the logs of each run are kept apart.
+// A symbolic link named latest in baseDir is pointed at the subdirectory.
+// Failing to update the link is logged, but is otherwise not an error.
+func OpenRunLogs(baseDir string, format LogFormat) (*Logger, error) {
+	baseDir, err := filepath.Abs(baseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.MkdirAll(baseDir, dirPerm)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := mkRunDir(baseDir, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := OpenLogsFormat(filepath.Join(baseDir, name), format)
+	if err != nil {
+		return nil, err
+	}
+
+	err = linkLatest(baseDir, name)
+	if err != nil {
+		l.Log(LevelWARN, "Cannot link "+latestLink+" to "+l.dir+": "+err.Error())
+	}
+
+	return l, nil
+}
+
+// mkRunDir creates a run directory in baseDir named after t and returns its name.
+// If the directory already exists, a numeric suffix is added to the name.
+func mkRunDir(baseDir string, t time.Time) (string, error) {
+	name := t.Format(runDirFormat)
+
+	for i := 2; ; i++ {
+		err := os.Mkdir(filepath.Join(baseDir, name), dirPerm)
+		if err == nil {
+			return name, nil
+		}
+
+		if !os.IsExist(err) {
+			return "", err
+		}
+
+		name = t.Format(runDirFormat) + "-" + strconv.Itoa(i)
+	}
+}
+
+// linkLatest points the latest symbolic link in baseDir at the named run directory.
+func linkLatest(baseDir, name string) error {
+	link := filepath.Join(baseDir, latestLink)
+
+	stat, err := os.Lstat(link)
+	if err == nil {
+		if stat.Mode()&fs.ModeSymlink == 0 {
+			return errors.New("file " + link + " exists")
+		}
+
+		err = os.Remove(link)
+		if err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	return os.Symlink(name, link)
+}
+
 // Dir returns the path logging directory.
 func (l *Logger) Dir() string {
 	l.filesMutex.Lock()
@@ -119,15 +225,16 @@ func (l *Logger) File(file string) *LogFile {
 
 	f, found := l.files[file]
 	if !found {
-		lF, err := openLogFile(filepath.Join(l.dir, fileDir, file))
+		lF, err := openLogFile(filepath.Join(l.dir, fileDir, file), l.format)
 		if err != nil {
 			format := "Cannot create log file for %s. Logging to main log file instead."
 			l.Log(LevelError, fmt.Sprintf(format, file))
 			l.Log(LevelError, err.Error())
 
-			lF = l.main
+			return l.main
 		}
 
+		l.files[file] = lF
 		f = lF
 	}
 
@@ -148,3 +255,8 @@ func (l *Logger) WriteString(entry string) (int, error) {
 func (l *Logger) Log(level LogLevel, entry string) error {
 	return l.main.Log(level, entry)
 }
+
+// LogWith logs an entry with its structured context at the specified level.
+func (l *Logger) LogWith(level LogLevel, entry string, fields Fields) error {
+	return l.main.LogWith(level, entry, fields)
+}
@@ -17,6 +17,8 @@ func main() {
 
 	validCommands["run"] = cmd.NewCmdMigrate()
 	validCommands["generate"] = cmd.NewCmdGenerate()
+	validCommands["verify"] = cmd.NewCmdVerify()
+	validCommands["validate"] = cmd.NewCmdValidate()
 
 	args := os.Args
 	if len(args) < 2 {